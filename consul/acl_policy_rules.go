@@ -0,0 +1,367 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	hclParser "github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// aclPolicyDispositions are the access levels that can be granted by the
+// rules of an ACL policy.
+var aclPolicyDispositions = []string{"read", "write", "deny", "list"}
+
+// aclPolicyDispositionAttrs are the attributes of the rules whose value must
+// be one of aclPolicyDispositions.
+var aclPolicyDispositionAttrs = map[string]bool{
+	"policy":     true,
+	"intentions": true,
+	"acl":        true,
+	"keyring":    true,
+	"mesh":       true,
+	"operator":   true,
+	"peering":    true,
+}
+
+// parseACLPolicyRules parses the rules of an ACL policy, written either in HCL
+// or in JSON like Consul accepts them, and returns them as nested maps so
+// that two documents granting the same permissions can be compared
+// regardless of their syntax, formatting or ordering.
+func parseACLPolicyRules(rules string) (map[string]interface{}, error) {
+	// The JSON parser of HCL does not report the position of all the
+	// errors, the standard library does
+	if strings.HasPrefix(strings.TrimSpace(rules), "{") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(rules), &v); err != nil {
+			if syntaxErr, ok := err.(*json.SyntaxError); ok {
+				line, column := offsetPosition(rules, syntaxErr.Offset)
+				return nil, aclPolicyRulesError(token.Pos{Line: line, Column: column}, "%v", err)
+			}
+			return nil, err
+		}
+	}
+
+	file, err := hcl.ParseString(rules)
+	if err != nil {
+		if posErr, ok := err.(*hclParser.PosError); ok {
+			return nil, aclPolicyRulesError(posErr.Pos, "%v", posErr.Err)
+		}
+		return nil, err
+	}
+
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected root node %T", file.Node)
+	}
+
+	result := map[string]interface{}{}
+	if err := normalizeACLPolicyRules(result, list); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func normalizeACLPolicyRules(result map[string]interface{}, list *ast.ObjectList) error {
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			return aclPolicyRulesError(item.Val.Pos(), "expected an attribute or a block")
+		}
+
+		// Blocks like `service "web" {}` are stored as nested objects so that
+		// they compare equal to their JSON counterpart
+		node := result
+		for i, key := range item.Keys[:len(item.Keys)-1] {
+			name := key.Token.Value().(string)
+			child, ok := node[name].(map[string]interface{})
+			if !ok {
+				if _, exists := node[name]; exists {
+					return aclPolicyRulesError(item.Keys[i].Pos(), "%q is both an attribute and a block", name)
+				}
+				child = map[string]interface{}{}
+				node[name] = child
+			}
+			node = child
+		}
+
+		last := item.Keys[len(item.Keys)-1]
+		name := last.Token.Value().(string)
+		value, err := normalizeACLPolicyValue(node[name], item.Val)
+		if err != nil {
+			return err
+		}
+
+		if aclPolicyDispositionAttrs[name] {
+			if err := validateACLPolicyDisposition(name, value, item.Val.Pos()); err != nil {
+				return err
+			}
+		}
+
+		node[name] = value
+	}
+	return nil
+}
+
+func normalizeACLPolicyValue(current interface{}, n ast.Node) (interface{}, error) {
+	switch v := n.(type) {
+	case *ast.LiteralType:
+		return v.Token.Value(), nil
+
+	case *ast.ObjectType:
+		// Repeated blocks are merged
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			obj = map[string]interface{}{}
+		}
+		if err := normalizeACLPolicyRules(obj, v.List); err != nil {
+			return nil, err
+		}
+		return obj, nil
+
+	case *ast.ListType:
+		// The JSON syntax allows a list of objects in place of an object
+		objects := true
+		for _, elem := range v.List {
+			if _, ok := elem.(*ast.ObjectType); !ok {
+				objects = false
+			}
+		}
+		if objects && len(v.List) > 0 {
+			result := current
+			for _, elem := range v.List {
+				var err error
+				if result, err = normalizeACLPolicyValue(result, elem); err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		}
+
+		list := make([]interface{}, len(v.List))
+		for i, elem := range v.List {
+			value, err := normalizeACLPolicyValue(nil, elem)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		return list, nil
+
+	default:
+		return nil, aclPolicyRulesError(n.Pos(), "unexpected %T", n)
+	}
+}
+
+func validateACLPolicyDisposition(name string, value interface{}, pos token.Pos) error {
+	// Nested blocks are not dispositions, as in `partition "foo" { mesh = "read" }`
+	if _, ok := value.(map[string]interface{}); ok {
+		return nil
+	}
+
+	s, ok := value.(string)
+	if ok {
+		for _, disposition := range aclPolicyDispositions {
+			if s == disposition {
+				return nil
+			}
+		}
+	}
+	return aclPolicyRulesError(
+		pos, "invalid value %#v for %q, expected one of %q",
+		value, name, aclPolicyDispositions,
+	)
+}
+
+// aclPolicyRulesError returns an error reporting the position where it
+// happened when it is known. The JSON parser does not record the position of
+// the values.
+func aclPolicyRulesError(pos token.Pos, format string, a ...interface{}) error {
+	err := fmt.Errorf(format, a...)
+	if !pos.IsValid() {
+		return err
+	}
+	return fmt.Errorf("line %d, column %d: %v", pos.Line, pos.Column, err)
+}
+
+// offsetPosition returns the line and column of the byte at the given offset
+// in s, as reported by a json.SyntaxError.
+func offsetPosition(s string, offset int64) (int, int) {
+	// The offset is the number of bytes read before the error
+	if offset > 0 {
+		offset--
+	}
+	if offset > int64(len(s)) {
+		offset = int64(len(s))
+	}
+	before := s[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := int(offset) - strings.LastIndex(before, "\n")
+	return line, column
+}
+
+// validateACLPolicyRules reports the syntax errors in the rules of an ACL
+// policy at plan time.
+func validateACLPolicyRules(v interface{}, k string) ([]string, []error) {
+	if _, err := parseACLPolicyRules(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("failed to parse %q: %v", k, err)}
+	}
+	return nil, nil
+}
+
+// diffACLPolicyRules suppresses the diff between two ACL policy rules granting
+// the same permissions.
+func diffACLPolicyRules(k, old, new string, d *schema.ResourceData) bool {
+	o, err := parseACLPolicyRules(old)
+	if err != nil {
+		return false
+	}
+	n, err := parseACLPolicyRules(new)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(o, n)
+}
+
+// aclPolicyGlobalRules are the resource types of the rules that apply to the
+// whole cluster and are not scoped by a name.
+var aclPolicyGlobalRules = map[string]bool{
+	"acl":      true,
+	"keyring":  true,
+	"mesh":     true,
+	"operator": true,
+	"peering":  true,
+}
+
+// aclPolicyRuleTypes are the resource types supported by the rule blocks of
+// consul_acl_policy.
+var aclPolicyRuleTypes = []string{
+	"acl", "agent", "agent_prefix", "event", "event_prefix", "key",
+	"key_prefix", "keyring", "mesh", "node", "node_prefix", "operator",
+	"peering", "query", "query_prefix", "service", "service_prefix",
+	"session", "session_prefix",
+}
+
+// renderACLPolicyRules renders rule blocks as canonical HCL rules: the global
+// rules come first, followed by the other ones sorted by resource type and
+// name.
+func renderACLPolicyRules(raw []interface{}) (string, error) {
+	type rule struct {
+		resourceType, name, policy string
+	}
+
+	rules := make([]rule, 0, len(raw))
+	seen := map[string]string{}
+	for _, r := range raw {
+		m := r.(map[string]interface{})
+		rule := rule{
+			resourceType: m["resource_type"].(string),
+			name:         m["name"].(string),
+			policy:       m["policy"].(string),
+		}
+
+		if aclPolicyGlobalRules[rule.resourceType] && rule.name != "" {
+			return "", fmt.Errorf("the %s rule does not support a name, got %q", rule.resourceType, rule.name)
+		}
+
+		id := rule.resourceType + " " + strconv.Quote(rule.name)
+		if policy, ok := seen[id]; ok && policy != rule.policy {
+			return "", fmt.Errorf("conflicting policies %q and %q for the %s rule %q", policy, rule.policy, rule.resourceType, rule.name)
+		}
+		seen[id] = rule.policy
+
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if aclPolicyGlobalRules[a.resourceType] != aclPolicyGlobalRules[b.resourceType] {
+			return aclPolicyGlobalRules[a.resourceType]
+		}
+		if a.resourceType != b.resourceType {
+			return a.resourceType < b.resourceType
+		}
+		return a.name < b.name
+	})
+
+	var b strings.Builder
+	for i, rule := range rules {
+		if i > 0 && rule == rules[i-1] {
+			continue
+		}
+		if aclPolicyGlobalRules[rule.resourceType] {
+			fmt.Fprintf(&b, "%s = %q\n", rule.resourceType, rule.policy)
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %q {\n  policy = %q\n}\n", rule.resourceType, rule.name, rule.policy)
+	}
+	return b.String(), nil
+}
+
+// aclPolicyRuleBlocks parses rules back into rule blocks. It returns false
+// when the rules use features that cannot be represented with rule blocks,
+// like namespaces or intentions.
+func aclPolicyRuleBlocks(rules string) ([]interface{}, bool) {
+	parsed, err := parseACLPolicyRules(rules)
+	if err != nil {
+		return nil, false
+	}
+
+	supported := map[string]bool{}
+	for _, t := range aclPolicyRuleTypes {
+		supported[t] = true
+	}
+
+	blocks := []interface{}{}
+	for resourceType, value := range parsed {
+		if !supported[resourceType] {
+			return nil, false
+		}
+
+		if aclPolicyGlobalRules[resourceType] {
+			policy, ok := value.(string)
+			if !ok {
+				return nil, false
+			}
+			blocks = append(blocks, map[string]interface{}{
+				"resource_type": resourceType,
+				"name":          "",
+				"policy":        policy,
+			})
+			continue
+		}
+
+		named, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		for name, raw := range named {
+			attrs, ok := raw.(map[string]interface{})
+			if !ok || len(attrs) != 1 {
+				return nil, false
+			}
+			policy, ok := attrs["policy"].(string)
+			if !ok {
+				return nil, false
+			}
+			blocks = append(blocks, map[string]interface{}{
+				"resource_type": resourceType,
+				"name":          name,
+				"policy":        policy,
+			})
+		}
+	}
+	return blocks, true
+}
@@ -0,0 +1,342 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseACLPolicyRules_corpus(t *testing.T) {
+	files, err := filepath.Glob("test-fixtures/acl-policies/*.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no policy found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			hclRules, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			jsonRules, err := os.ReadFile(strings.TrimSuffix(file, ".hcl") + ".json")
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			fromHCL, err := parseACLPolicyRules(string(hclRules))
+			if err != nil {
+				t.Fatalf("failed to parse HCL rules: %s", err)
+			}
+			fromJSON, err := parseACLPolicyRules(string(jsonRules))
+			if err != nil {
+				t.Fatalf("failed to parse JSON rules: %s", err)
+			}
+			if !reflect.DeepEqual(fromHCL, fromJSON) {
+				t.Fatalf("expected the rules to be equal:\n%#v\n%#v", fromHCL, fromJSON)
+			}
+			if !diffACLPolicyRules("rules", string(hclRules), string(jsonRules), nil) {
+				t.Fatal("expected the diff to be suppressed")
+			}
+		})
+	}
+}
+
+func TestDiffACLPolicyRules(t *testing.T) {
+	cases := map[string]struct {
+		old, new string
+		equal    bool
+	}{
+		"formatting": {
+			`node_prefix "" { policy = "read" }`,
+			`
+node_prefix "" {
+  # Needed for DNS
+  policy = "read"
+}
+`,
+			true,
+		},
+		"order": {
+			`key_prefix "a/" { policy = "read" }
+key_prefix "b/" { policy = "write" }`,
+			`key_prefix "b/" { policy = "write" }
+key_prefix "a/" { policy = "read" }`,
+			true,
+		},
+		"different policy": {
+			`key_prefix "a/" { policy = "read" }`,
+			`key_prefix "a/" { policy = "write" }`,
+			false,
+		},
+		"different name": {
+			`service "web" { policy = "read" }`,
+			`service "api" { policy = "read" }`,
+			false,
+		},
+		"additional rule": {
+			`operator = "read"`,
+			`operator = "read"
+mesh = "read"`,
+			false,
+		},
+		"invalid": {
+			`operator = "read`,
+			`operator = "read`,
+			false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := diffACLPolicyRules("rules", tc.old, tc.new, nil); got != tc.equal {
+				t.Fatalf("expected %t, got %t", tc.equal, got)
+			}
+		})
+	}
+}
+
+func TestValidateACLPolicyRules(t *testing.T) {
+	cases := map[string]struct {
+		rules string
+		err   string
+	}{
+		"empty": {
+			rules: "",
+		},
+		"valid": {
+			rules: `service "web" { policy = "write" }`,
+		},
+		"hcl syntax": {
+			rules: `
+node_prefix "" {
+  policy = "read"
+
+service "web" {
+  policy = "write"
+}
+`,
+			err: `failed to parse "rules": line 8, column 2: object expected closing RBRACE got: EOF`,
+		},
+		"hcl missing value": {
+			rules: `
+key_prefix "" {
+  policy =
+}
+`,
+			err: `failed to parse "rules": line 5, column 1: object expected closing RBRACE got: EOF`,
+		},
+		"json syntax": {
+			rules: `{
+  "key_prefix": {
+    "": {"policy": "read",}
+  }
+}`,
+			err: `failed to parse "rules": line 3, column 27: invalid character '}' looking for beginning of object key string`,
+		},
+		"invalid policy": {
+			rules: `
+service_prefix "" {
+  policy = "admin"
+}
+`,
+			err: `failed to parse "rules": line 3, column 12: invalid value "admin" for "policy", expected one of ["read" "write" "deny" "list"]`,
+		},
+		"invalid json policy": {
+			rules: `{"operator": "all"}`,
+			err:   `failed to parse "rules": invalid value "all" for "operator", expected one of ["read" "write" "deny" "list"]`,
+		},
+		"invalid type": {
+			rules: `mesh = true`,
+			err:   `failed to parse "rules": line 1, column 8: invalid value true for "mesh", expected one of ["read" "write" "deny" "list"]`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, errs := validateACLPolicyRules(tc.rules, "rules")
+			if tc.err == "" {
+				if len(errs) != 0 {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 {
+				t.Fatalf("expected 1 error, got %v", errs)
+			}
+			if errs[0].Error() != tc.err {
+				t.Fatalf("expected %q, got %q", tc.err, errs[0])
+			}
+		})
+	}
+}
+
+func TestRenderACLPolicyRules(t *testing.T) {
+	block := func(resourceType, name, policy string) interface{} {
+		return map[string]interface{}{
+			"resource_type": resourceType,
+			"name":          name,
+			"policy":        policy,
+		}
+	}
+
+	cases := map[string]struct {
+		blocks []interface{}
+		rules  string
+		err    string
+	}{
+		"canonical": {
+			blocks: []interface{}{
+				block("service_prefix", "", "read"),
+				block("key_prefix", "vault/", "write"),
+				block("operator", "", "read"),
+				block("node_prefix", "", "read"),
+				block("acl", "", "write"),
+				block("service", "web", "write"),
+				block("service", "api", "read"),
+			},
+			rules: `acl = "write"
+operator = "read"
+
+key_prefix "vault/" {
+  policy = "write"
+}
+
+node_prefix "" {
+  policy = "read"
+}
+
+service "api" {
+  policy = "read"
+}
+
+service "web" {
+  policy = "write"
+}
+
+service_prefix "" {
+  policy = "read"
+}
+`,
+		},
+		"duplicates": {
+			blocks: []interface{}{
+				block("key", "foo", "read"),
+				block("key", "foo", "read"),
+			},
+			rules: `key "foo" {
+  policy = "read"
+}
+`,
+		},
+		"global rule with a name": {
+			blocks: []interface{}{
+				block("mesh", "foo", "read"),
+			},
+			err: `the mesh rule does not support a name, got "foo"`,
+		},
+		"conflicting policies": {
+			blocks: []interface{}{
+				block("service", "web", "read"),
+				block("service", "web", "write"),
+			},
+			err: `conflicting policies "read" and "write" for the service rule "web"`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rules, err := renderACLPolicyRules(tc.blocks)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if rules != tc.rules {
+				t.Fatalf("bad rules:\n%s\nexpected:\n%s", rules, tc.rules)
+			}
+			if _, err := parseACLPolicyRules(rules); err != nil {
+				t.Fatalf("the rendered rules are invalid: %s", err)
+			}
+
+			blocks, ok := aclPolicyRuleBlocks(rules)
+			if !ok {
+				t.Fatal("expected the rules to be represented as blocks")
+			}
+			roundTrip, err := renderACLPolicyRules(blocks)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if roundTrip != rules {
+				t.Fatalf("bad round trip:\n%s\nexpected:\n%s", roundTrip, rules)
+			}
+		})
+	}
+}
+
+func TestACLPolicyRuleBlocks(t *testing.T) {
+	cases := map[string]struct {
+		rules string
+		ok    bool
+	}{
+		"anonymous": {
+			rules: readACLPolicyFixture(t, "anonymous.hcl"),
+			ok:    true,
+		},
+		"json": {
+			rules: readACLPolicyFixture(t, "anonymous.json"),
+			ok:    true,
+		},
+		"partitions": {
+			rules: readACLPolicyFixture(t, "partitions.hcl"),
+			ok:    false,
+		},
+		"intentions": {
+			rules: `service "web" { policy = "read" intentions = "write" }`,
+			ok:    false,
+		},
+		"invalid": {
+			rules: `service "web" {`,
+			ok:    false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			blocks, ok := aclPolicyRuleBlocks(tc.rules)
+			if ok != tc.ok {
+				t.Fatalf("expected %v, got %v", tc.ok, ok)
+			}
+			if !ok {
+				return
+			}
+
+			rendered, err := renderACLPolicyRules(blocks)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !diffACLPolicyRules("rules", tc.rules, rendered, nil) {
+				t.Fatalf("the rendered rules differ from the original ones:\n%s", rendered)
+			}
+		})
+	}
+}
+
+func readACLPolicyFixture(t *testing.T, name string) string {
+	content, err := os.ReadFile(filepath.Join("test-fixtures/acl-policies", name))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return string(content)
+}
@@ -121,6 +121,8 @@ type AuthLoginCommon struct {
 	authField   string
 	params      map[string]interface{}
 	initialized bool
+	// writeOptions are the default options of the login request
+	writeOptions *consulapi.WriteOptions
 }
 
 func (l *AuthLoginCommon) Params() map[string]interface{} {
@@ -208,11 +210,22 @@ func (l *AuthLoginCommon) copyParamsExcluding(excludes ...string) (map[string]in
 }
 
 func (l *AuthLoginCommon) login(client *consulapi.Client, authMethodName string, bearerToken string, meta map[string]string) (string, error) {
+	wOpts := &consulapi.WriteOptions{}
+	if l.writeOptions != nil {
+		*wOpts = *l.writeOptions
+	}
+	if ns, ok := l.Namespace(); ok {
+		wOpts.Namespace = ns
+	}
+	if part, ok := l.Partition(); ok {
+		wOpts.Partition = part
+	}
+
 	token, _, err := client.ACL().Login(&consulapi.ACLLoginParams{
 		AuthMethod:  authMethodName,
 		BearerToken: bearerToken,
 		Meta:        meta,
-	}, nil)
+	}, wOpts)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,249 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-provider-consul/consul/auth/utils"
+)
+
+// jwtBearerTokenSources lists the fields that can provide the bearer token,
+// in order of precedence.
+var jwtBearerTokenSources = []string{
+	utils.FieldBearerToken,
+	utils.FieldBearerTokenFile,
+	utils.FieldBearerTokenEnv,
+	utils.FieldUseTFCWorkloadIdentity,
+}
+
+// legacyJWTBearerTokenSources lists the fields of the deprecated auth_jwt
+// block that can provide the bearer token, in order of precedence.
+var legacyJWTBearerTokenSources = []string{
+	utils.FieldUseTFCWorkloadIdentity,
+	utils.FieldBearerToken,
+}
+
+func init() {
+	field := utils.FieldAuthLoginJWT
+	if err := globalAuthLoginRegistry.Register(field,
+		func(r *schema.ResourceData) (AuthLogin, error) {
+			a := &AuthLoginJWT{}
+			return a.Init(r, field)
+		}, GetJWTLoginSchema); err != nil {
+		panic(err)
+	}
+}
+
+// GetJWTLoginSchema for the JWT and OIDC authentication engines.
+func GetJWTLoginSchema(authField string) *schema.Schema {
+	return getLoginSchema(
+		authField,
+		"Login to Consul using a JWT or OIDC auth method",
+		GetJWTLoginSchemaResource,
+	)
+}
+
+// GetJWTLoginSchemaResource for the JWT and OIDC authentication engines.
+func GetJWTLoginSchemaResource(authField string) *schema.Resource {
+	return mustAddLoginSchema(&schema.Resource{
+		Schema: map[string]*schema.Schema{
+			utils.FieldAuthMethod: {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: `The name of the Consul auth method to use for login.`,
+			},
+			utils.FieldBearerToken: {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: `The bearer token to present to the auth method during login.`,
+			},
+			utils.FieldBearerTokenFile: {
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: `Path to a file containing the bearer token. The file is read ` +
+					`each time the provider logs in.`,
+			},
+			utils.FieldBearerTokenEnv: {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: `The name of the environment variable containing the bearer token.`,
+			},
+			utils.FieldUseTFCWorkloadIdentity: {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Description: `Whether to use a Terraform Workload Identity token. The token will ` +
+					`be read from the ` + "`" + utils.EnvVarTFCWorkloadIdentityToken + "`" + ` environment variable.`,
+			},
+			utils.FieldMeta: {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: `Specifies arbitrary KV metadata linked to the token. Can be useful to track origins.`,
+			},
+		},
+	}, authField)
+}
+
+// NewLegacyAuthLoginJWT returns an AuthLogin for the deprecated auth_jwt
+// provider block. The legacy block has no datacenter, namespace, partition or
+// token of its own, those of the provider given in wOpts are used for the
+// login request instead.
+func NewLegacyAuthLoginJWT(d *schema.ResourceData, wOpts *consulapi.WriteOptions) (AuthLogin, error) {
+	a := &AuthLoginJWT{legacy: true}
+	if _, err := a.Init(d, utils.FieldAuthJWT); err != nil {
+		return nil, err
+	}
+
+	a.writeOptions = wOpts
+	if _, ok := a.Namespace(); !ok && wOpts.Namespace != "" {
+		a.params["namespace"] = wOpts.Namespace
+	}
+
+	return a, nil
+}
+
+var _ AuthLogin = (*AuthLoginJWT)(nil)
+
+// AuthLoginJWT for handling the Consul JWT and OIDC authentication methods.
+// Requires configuration provided by SchemaLoginJWT.
+type AuthLoginJWT struct {
+	AuthLoginCommon
+
+	// legacy is set for the deprecated auth_jwt block
+	legacy bool
+}
+
+func (l *AuthLoginJWT) Init(d *schema.ResourceData, authField string) (AuthLogin, error) {
+	if err := l.AuthLoginCommon.Init(d, authField,
+		func(data *schema.ResourceData, params map[string]interface{}) error {
+			return l.checkRequiredFields(d, params, utils.FieldAuthMethod)
+		},
+		func(data *schema.ResourceData, params map[string]interface{}) error {
+			if err := l.checkFieldsOneOf(d, l.bearerTokenSources()...); err != nil {
+				if l.legacy {
+					return fmt.Errorf("either %[1]s.%[2]s or %[1]s.%[3]s should be set",
+						authField, utils.FieldBearerToken, utils.FieldUseTFCWorkloadIdentity)
+				}
+				return fmt.Errorf("%s: %w", authField, err)
+			}
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// AuthMethodName returns the Consul auth method name.
+func (l *AuthLoginJWT) AuthMethodName() string {
+	if v, ok := l.params[utils.FieldAuthMethod].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Login using the JWT authentication method.
+func (l *AuthLoginJWT) Login(client *consulapi.Client) (string, error) {
+	if err := l.validate(); err != nil {
+		return "", err
+	}
+
+	authMethod := l.AuthMethodName()
+	if authMethod == "" {
+		return "", fmt.Errorf("auth_method is required")
+	}
+
+	bearerToken, err := l.readBearerToken()
+	if err != nil {
+		return "", err
+	}
+
+	meta := make(map[string]string)
+	if metaRaw, ok := l.params[utils.FieldMeta].(map[string]interface{}); ok {
+		for k, v := range metaRaw {
+			if strVal, ok := v.(string); ok {
+				meta[k] = strVal
+			}
+		}
+	}
+
+	return l.login(client, authMethod, bearerToken, meta)
+}
+
+// bearerTokenSources returns the fields that can provide the bearer token.
+func (l *AuthLoginJWT) bearerTokenSources() []string {
+	if l.legacy {
+		return legacyJWTBearerTokenSources
+	}
+	return jwtBearerTokenSources
+}
+
+// readBearerToken resolves the bearer token from the first configured source.
+// Files and environment variables are read on every call so that a token
+// rotated during a long run is picked up on the next login.
+func (l *AuthLoginJWT) readBearerToken() (string, error) {
+	for _, source := range l.bearerTokenSources() {
+		token, ok, err := l.readBearerTokenFrom(source)
+		if err != nil || ok {
+			return token, err
+		}
+	}
+
+	return "", fmt.Errorf("%s: at least one field must be set: %v", l.authField, l.bearerTokenSources())
+}
+
+// readBearerTokenFrom reads the bearer token from source, ok is false when
+// source is not set.
+func (l *AuthLoginJWT) readBearerTokenFrom(source string) (string, bool, error) {
+	switch source {
+	case utils.FieldBearerToken:
+		if v := utils.GetStringParam(l.params, utils.FieldBearerToken); v != "" {
+			return v, true, nil
+		}
+
+	case utils.FieldBearerTokenFile:
+		if path := utils.GetStringParam(l.params, utils.FieldBearerTokenFile); path != "" {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return "", false, fmt.Errorf("failed to read bearer token: %w", err)
+			}
+			token := strings.TrimSpace(string(b))
+			if token == "" {
+				return "", false, fmt.Errorf("bearer token file %q is empty", path)
+			}
+			return token, true, nil
+		}
+
+	case utils.FieldBearerTokenEnv:
+		if envVar := utils.GetStringParam(l.params, utils.FieldBearerTokenEnv); envVar != "" {
+			token := strings.TrimSpace(os.Getenv(envVar))
+			if token == "" {
+				return "", false, fmt.Errorf("%s.%s has been set but no token found in %s environment variable",
+					l.authField, utils.FieldBearerTokenEnv, envVar)
+			}
+			return token, true, nil
+		}
+
+	case utils.FieldUseTFCWorkloadIdentity:
+		if v, ok := l.params[utils.FieldUseTFCWorkloadIdentity].(bool); ok && v {
+			token := strings.TrimSpace(os.Getenv(utils.EnvVarTFCWorkloadIdentityToken))
+			if token == "" {
+				return "", false, fmt.Errorf("%s.%s has been set but no token found in %s environment variable",
+					l.authField, utils.FieldUseTFCWorkloadIdentity, utils.EnvVarTFCWorkloadIdentityToken)
+			}
+			return token, true, nil
+		}
+	}
+
+	return "", false, nil
+}
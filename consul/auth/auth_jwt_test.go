@@ -0,0 +1,278 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-provider-consul/consul/auth/utils"
+)
+
+func TestAuthLoginJWT_Init(t *testing.T) {
+	tests := []authLoginInitTest{
+		{
+			name:      "basic",
+			authField: utils.FieldAuthLoginJWT,
+			raw: map[string]interface{}{
+				utils.FieldAuthLoginJWT: []interface{}{
+					map[string]interface{}{
+						"namespace":            "ns1",
+						"partition":            "part1",
+						utils.FieldAuthMethod:  "jwt-auth",
+						utils.FieldBearerToken: "token",
+					},
+				},
+			},
+			expectParams: map[string]interface{}{
+				"namespace":                       "ns1",
+				"partition":                       "part1",
+				utils.FieldAuthMethod:             "jwt-auth",
+				utils.FieldBearerToken:            "token",
+				utils.FieldBearerTokenFile:        "",
+				utils.FieldBearerTokenEnv:         "",
+				utils.FieldUseTFCWorkloadIdentity: false,
+				utils.FieldMeta:                   map[string]interface{}{},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "bearer-token-file",
+			authField: utils.FieldAuthLoginJWT,
+			raw: map[string]interface{}{
+				utils.FieldAuthLoginJWT: []interface{}{
+					map[string]interface{}{
+						utils.FieldAuthMethod:      "jwt-auth",
+						utils.FieldBearerTokenFile: "/tmp/jwt",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "error-no-token-source",
+			authField: utils.FieldAuthLoginJWT,
+			raw: map[string]interface{}{
+				utils.FieldAuthLoginJWT: []interface{}{
+					map[string]interface{}{
+						utils.FieldAuthMethod: "jwt-auth",
+					},
+				},
+			},
+			wantErr: true,
+			expectErr: fmt.Errorf("%s: at least one field must be set: %v",
+				utils.FieldAuthLoginJWT, jwtBearerTokenSources),
+		},
+		{
+			name:      "error-missing-resource",
+			authField: utils.FieldAuthLoginJWT,
+			wantErr:   true,
+			expectErr: fmt.Errorf("resource data missing field %q", utils.FieldAuthLoginJWT),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := map[string]*schema.Schema{
+				tt.authField: GetJWTLoginSchema(tt.authField),
+			}
+			assertAuthLoginInit(t, tt, s, &AuthLoginJWT{})
+		})
+	}
+}
+
+func TestNewLegacyAuthLoginJWT(t *testing.T) {
+	// Mirrors the auth_jwt block of the provider schema.
+	s := map[string]*schema.Schema{
+		utils.FieldAuthJWT: {
+			Type:     schema.TypeList,
+			Optional: true,
+			MaxItems: 1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					utils.FieldAuthMethod:             {Type: schema.TypeString, Required: true},
+					utils.FieldBearerToken:            {Type: schema.TypeString, Optional: true},
+					utils.FieldUseTFCWorkloadIdentity: {Type: schema.TypeBool, Optional: true},
+					utils.FieldMeta: {
+						Type:     schema.TypeMap,
+						Optional: true,
+						Elem:     &schema.Schema{Type: schema.TypeString},
+					},
+				},
+			},
+		},
+	}
+
+	r := schema.TestResourceDataRaw(t, s, map[string]interface{}{
+		utils.FieldAuthJWT: []interface{}{
+			map[string]interface{}{
+				utils.FieldAuthMethod:  "jwt-auth",
+				utils.FieldBearerToken: "token",
+			},
+		},
+	})
+
+	wOpts := &consulapi.WriteOptions{
+		Datacenter: "provider-dc",
+		Namespace:  "provider-ns",
+		Token:      "provider-token",
+	}
+	a, err := NewLegacyAuthLoginJWT(r, wOpts)
+	if err != nil {
+		t.Fatalf("NewLegacyAuthLoginJWT() unexpected error = %v", err)
+	}
+	if a.AuthMethodName() != "jwt-auth" {
+		t.Errorf("AuthMethodName() = %q, want %q", a.AuthMethodName(), "jwt-auth")
+	}
+	if ns, _ := a.Namespace(); ns != "provider-ns" {
+		t.Errorf("Namespace() = %q, want %q", ns, "provider-ns")
+	}
+
+	// The login request uses the options of the provider
+	handler := &testLoginHandler{
+		handlerFunc: func(h *testLoginHandler, w http.ResponseWriter, req *http.Request) {
+			if dc := req.URL.Query().Get("dc"); dc != "provider-dc" {
+				t.Errorf("expected datacenter %q, got %q", "provider-dc", dc)
+			}
+			if ns := req.URL.Query().Get("ns"); ns != "provider-ns" {
+				t.Errorf("expected namespace %q, got %q", "provider-ns", ns)
+			}
+			if token := req.Header.Get("X-Consul-Token"); token != "provider-token" {
+				t.Errorf("expected token %q, got %q", "provider-token", token)
+			}
+			w.Header().Set("Content-Type", utils.HTTPContentTypeJSON)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"SecretID": "jwt-secret-token",
+			})
+		},
+	}
+	c, err := consulapi.NewClient(&consulapi.Config{Address: testHTTPServer(t, handler.handler())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := a.Login(c); err != nil || token != "jwt-secret-token" {
+		t.Errorf("Login() = %q, %v", token, err)
+	}
+
+	r = schema.TestResourceDataRaw(t, s, map[string]interface{}{
+		utils.FieldAuthJWT: []interface{}{
+			map[string]interface{}{
+				utils.FieldAuthMethod: "jwt-auth",
+			},
+		},
+	})
+	_, err = NewLegacyAuthLoginJWT(r, &consulapi.WriteOptions{})
+	expectErr := "either auth_jwt.bearer_token or auth_jwt.use_terraform_cloud_workload_identity should be set"
+	if err == nil || err.Error() != expectErr {
+		t.Errorf("NewLegacyAuthLoginJWT() expected error %q, actual %v", expectErr, err)
+	}
+}
+
+func TestAuthLoginJWT_Login(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "jwt")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TEST_CONSUL_JWT", "env-token")
+
+	successHandler := func(h *testLoginHandler, w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", utils.HTTPContentTypeJSON)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"SecretID": "jwt-secret-token",
+		})
+	}
+
+	newAuthLogin := func(params map[string]interface{}) *AuthLoginJWT {
+		params[utils.FieldAuthMethod] = "jwt-auth"
+		return &AuthLoginJWT{
+			AuthLoginCommon: AuthLoginCommon{
+				authField:   utils.FieldAuthLoginJWT,
+				initialized: true,
+				params:      params,
+			},
+		}
+	}
+
+	tests := []authLoginTest{
+		{
+			name: "inline-token",
+			authLogin: newAuthLogin(map[string]interface{}{
+				utils.FieldBearerToken: "inline-token",
+				utils.FieldMeta: map[string]interface{}{
+					"origin": "terraform",
+				},
+			}),
+			handler:        &testLoginHandler{handlerFunc: successHandler},
+			want:           "jwt-secret-token",
+			expectReqCount: 1,
+			expectReqParams: []map[string]interface{}{
+				{
+					"AuthMethod":  "jwt-auth",
+					"BearerToken": "inline-token",
+					"Meta": map[string]interface{}{
+						"origin": "terraform",
+					},
+				},
+			},
+		},
+		{
+			name: "token-from-file",
+			authLogin: newAuthLogin(map[string]interface{}{
+				utils.FieldBearerTokenFile: tokenFile,
+			}),
+			handler:        &testLoginHandler{handlerFunc: successHandler},
+			want:           "jwt-secret-token",
+			expectReqCount: 1,
+			expectReqParams: []map[string]interface{}{
+				{
+					"AuthMethod":  "jwt-auth",
+					"BearerToken": "file-token",
+				},
+			},
+		},
+		{
+			name: "token-from-env",
+			authLogin: newAuthLogin(map[string]interface{}{
+				utils.FieldBearerTokenEnv: "TEST_CONSUL_JWT",
+			}),
+			handler:        &testLoginHandler{handlerFunc: successHandler},
+			want:           "jwt-secret-token",
+			expectReqCount: 1,
+			expectReqParams: []map[string]interface{}{
+				{
+					"AuthMethod":  "jwt-auth",
+					"BearerToken": "env-token",
+				},
+			},
+		},
+		{
+			name: "error-tfc-token-unset",
+			authLogin: newAuthLogin(map[string]interface{}{
+				utils.FieldUseTFCWorkloadIdentity: true,
+			}),
+			preLoginFunc: func(t *testing.T) {
+				t.Setenv(utils.EnvVarTFCWorkloadIdentityToken, "")
+			},
+			handler:            &testLoginHandler{handlerFunc: successHandler},
+			expectReqCount:     0,
+			skipCheckReqParams: true,
+			wantErr:            true,
+			expectErr: errors.New("auth_login_jwt.use_terraform_cloud_workload_identity has been set " +
+				"but no token found in TFC_WORKLOAD_IDENTITY_TOKEN environment variable"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAuthLogin(t, tt)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-provider-consul/consul/auth/utils"
+)
+
+func init() {
+	field := utils.FieldAuthLoginKubernetes
+	if err := globalAuthLoginRegistry.Register(field,
+		func(r *schema.ResourceData) (AuthLogin, error) {
+			a := &AuthLoginKubernetes{}
+			return a.Init(r, field)
+		}, GetKubernetesLoginSchema); err != nil {
+		panic(err)
+	}
+}
+
+// GetKubernetesLoginSchema for the Kubernetes authentication engine.
+func GetKubernetesLoginSchema(authField string) *schema.Schema {
+	return getLoginSchema(
+		authField,
+		"Login to Consul using the Kubernetes auth method",
+		GetKubernetesLoginSchemaResource,
+	)
+}
+
+// GetKubernetesLoginSchemaResource for the Kubernetes authentication engine.
+func GetKubernetesLoginSchemaResource(authField string) *schema.Resource {
+	return mustAddLoginSchema(&schema.Resource{
+		Schema: map[string]*schema.Schema{
+			utils.FieldAuthMethod: {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: `The name of the Consul auth method to use for login.`,
+			},
+			utils.FieldServiceAccountTokenFile: {
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: `Path to the Kubernetes service account token. Defaults to ` +
+					`"` + utils.DefaultKubernetesServiceAccountTokenFile + `".`,
+			},
+			utils.FieldBearerToken: {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+				Description: `The service account token to present to the auth method. ` +
+					`Takes precedence over the service account token file.`,
+			},
+			utils.FieldMeta: {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Description: `Specifies arbitrary KV metadata linked to the token. Can be useful to track origins.`,
+			},
+		},
+	}, authField)
+}
+
+var _ AuthLogin = (*AuthLoginKubernetes)(nil)
+
+// AuthLoginKubernetes for handling the Consul Kubernetes authentication method.
+// Requires configuration provided by SchemaLoginKubernetes.
+type AuthLoginKubernetes struct {
+	AuthLoginCommon
+}
+
+func (l *AuthLoginKubernetes) Init(d *schema.ResourceData, authField string) (AuthLogin, error) {
+	defaults := l.getDefaults()
+	if err := l.AuthLoginCommon.Init(d, authField,
+		func(data *schema.ResourceData, params map[string]interface{}) error {
+			return l.setDefaultFields(d, defaults, params)
+		},
+		func(data *schema.ResourceData, params map[string]interface{}) error {
+			return l.checkRequiredFields(d, params, utils.FieldAuthMethod)
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// AuthMethodName returns the Consul auth method name.
+func (l *AuthLoginKubernetes) AuthMethodName() string {
+	if v, ok := l.params[utils.FieldAuthMethod].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Login using the Kubernetes authentication method.
+func (l *AuthLoginKubernetes) Login(client *consulapi.Client) (string, error) {
+	if err := l.validate(); err != nil {
+		return "", err
+	}
+
+	authMethod := l.AuthMethodName()
+	if authMethod == "" {
+		return "", fmt.Errorf("auth_method is required")
+	}
+
+	bearerToken, err := l.readBearerToken()
+	if err != nil {
+		return "", err
+	}
+
+	meta := make(map[string]string)
+	if metaRaw, ok := l.params[utils.FieldMeta].(map[string]interface{}); ok {
+		for k, v := range metaRaw {
+			if strVal, ok := v.(string); ok {
+				meta[k] = strVal
+			}
+		}
+	}
+
+	return l.login(client, authMethod, bearerToken, meta)
+}
+
+// readBearerToken returns the configured bearer token, or reads it from the
+// service account token file. The file is read on every call so that
+// projected tokens rotated by the kubelet are picked up.
+func (l *AuthLoginKubernetes) readBearerToken() (string, error) {
+	if v := utils.GetStringParam(l.params, utils.FieldBearerToken); v != "" {
+		return v, nil
+	}
+
+	path := utils.GetStringParam(l.params, utils.FieldServiceAccountTokenFile)
+	if path == "" {
+		path = utils.DefaultKubernetesServiceAccountTokenFile
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("service account token file %q is empty", path)
+	}
+
+	return token, nil
+}
+
+func (l *AuthLoginKubernetes) getDefaults() authDefaults {
+	defaults := authDefaults{
+		{
+			field:      utils.FieldBearerToken,
+			envVars:    []string{utils.EnvVarBearerToken},
+			defaultVal: "",
+		},
+		{
+			field:      utils.FieldServiceAccountTokenFile,
+			envVars:    []string{utils.EnvVarKubernetesServiceAccountTokenFile},
+			defaultVal: utils.DefaultKubernetesServiceAccountTokenFile,
+		},
+	}
+
+	return defaults
+}
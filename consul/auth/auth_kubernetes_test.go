@@ -0,0 +1,261 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-provider-consul/consul/auth/utils"
+)
+
+func TestAuthLoginKubernetes_Init(t *testing.T) {
+	tests := []authLoginInitTest{
+		{
+			name:      "basic",
+			authField: utils.FieldAuthLoginKubernetes,
+			raw: map[string]interface{}{
+				utils.FieldAuthLoginKubernetes: []interface{}{
+					map[string]interface{}{
+						"namespace":                        "ns1",
+						"partition":                        "part1",
+						utils.FieldAuthMethod:              "k8s-auth",
+						utils.FieldServiceAccountTokenFile: "/tmp/token",
+					},
+				},
+			},
+			expectParams: map[string]interface{}{
+				"namespace":                        "ns1",
+				"partition":                        "part1",
+				utils.FieldAuthMethod:              "k8s-auth",
+				utils.FieldServiceAccountTokenFile: "/tmp/token",
+				utils.FieldBearerToken:             "",
+				utils.FieldMeta:                    map[string]interface{}{},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "default-token-file",
+			authField: utils.FieldAuthLoginKubernetes,
+			raw: map[string]interface{}{
+				utils.FieldAuthLoginKubernetes: []interface{}{
+					map[string]interface{}{
+						utils.FieldAuthMethod: "k8s-auth",
+					},
+				},
+			},
+			expectParams: map[string]interface{}{
+				"namespace":                        "",
+				"partition":                        "",
+				utils.FieldAuthMethod:              "k8s-auth",
+				utils.FieldServiceAccountTokenFile: utils.DefaultKubernetesServiceAccountTokenFile,
+				utils.FieldBearerToken:             "",
+				utils.FieldMeta:                    map[string]interface{}{},
+			},
+			wantErr: false,
+		},
+		{
+			name:      "with-env-vars",
+			authField: utils.FieldAuthLoginKubernetes,
+			raw: map[string]interface{}{
+				utils.FieldAuthLoginKubernetes: []interface{}{
+					map[string]interface{}{
+						utils.FieldAuthMethod: "k8s-auth",
+					},
+				},
+			},
+			envVars: map[string]string{
+				utils.EnvVarKubernetesServiceAccountTokenFile: "/env/token",
+				utils.EnvVarBearerToken:                       "env-token",
+			},
+			expectParams: map[string]interface{}{
+				"namespace":                        "",
+				"partition":                        "",
+				utils.FieldAuthMethod:              "k8s-auth",
+				utils.FieldServiceAccountTokenFile: "/env/token",
+				utils.FieldBearerToken:             "env-token",
+				utils.FieldMeta:                    map[string]interface{}{},
+			},
+			wantErr: false,
+		},
+		{
+			name:         "error-missing-resource",
+			authField:    utils.FieldAuthLoginKubernetes,
+			expectParams: nil,
+			wantErr:      true,
+			expectErr:    fmt.Errorf("resource data missing field %q", utils.FieldAuthLoginKubernetes),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := map[string]*schema.Schema{
+				tt.authField: GetKubernetesLoginSchema(tt.authField),
+			}
+			assertAuthLoginInit(t, tt, s, &AuthLoginKubernetes{})
+		})
+	}
+}
+
+func TestAuthLoginKubernetes_Login(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("sa-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	successHandler := func(h *testLoginHandler, w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/acl/login" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", utils.HTTPContentTypeJSON)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"SecretID": "k8s-secret-token",
+		})
+	}
+
+	tests := []authLoginTest{
+		{
+			name: "token-from-file",
+			authLogin: &AuthLoginKubernetes{
+				AuthLoginCommon: AuthLoginCommon{
+					authField:   utils.FieldAuthLoginKubernetes,
+					initialized: true,
+					params: map[string]interface{}{
+						utils.FieldAuthMethod:              "k8s-auth",
+						utils.FieldServiceAccountTokenFile: tokenFile,
+						utils.FieldMeta: map[string]interface{}{
+							"origin": "terraform",
+						},
+					},
+				},
+			},
+			handler: &testLoginHandler{
+				handlerFunc: successHandler,
+			},
+			want:           "k8s-secret-token",
+			expectReqCount: 1,
+			expectReqParams: []map[string]interface{}{
+				{
+					"AuthMethod":  "k8s-auth",
+					"BearerToken": "sa-token",
+					"Meta": map[string]interface{}{
+						"origin": "terraform",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "bearer-token-takes-precedence",
+			authLogin: &AuthLoginKubernetes{
+				AuthLoginCommon: AuthLoginCommon{
+					authField:   utils.FieldAuthLoginKubernetes,
+					initialized: true,
+					params: map[string]interface{}{
+						utils.FieldAuthMethod:              "k8s-auth",
+						utils.FieldBearerToken:             "inline-token",
+						utils.FieldServiceAccountTokenFile: tokenFile,
+					},
+				},
+			},
+			handler: &testLoginHandler{
+				handlerFunc: successHandler,
+			},
+			want:           "k8s-secret-token",
+			expectReqCount: 1,
+			expectReqParams: []map[string]interface{}{
+				{
+					"AuthMethod":  "k8s-auth",
+					"BearerToken": "inline-token",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "namespace-and-partition",
+			authLogin: &AuthLoginKubernetes{
+				AuthLoginCommon: AuthLoginCommon{
+					authField:   utils.FieldAuthLoginKubernetes,
+					initialized: true,
+					params: map[string]interface{}{
+						"namespace":                        "ns1",
+						"partition":                        "part1",
+						utils.FieldAuthMethod:              "k8s-auth",
+						utils.FieldServiceAccountTokenFile: tokenFile,
+					},
+				},
+			},
+			handler: &testLoginHandler{
+				handlerFunc: func(h *testLoginHandler, w http.ResponseWriter, req *http.Request) {
+					query := req.URL.Query()
+					if query.Get("ns") != "ns1" || query.Get("partition") != "part1" {
+						w.WriteHeader(http.StatusBadRequest)
+						_, _ = fmt.Fprintf(w, "unexpected query %q", req.URL.RawQuery)
+						return
+					}
+					successHandler(h, w, req)
+				},
+			},
+			want:           "k8s-secret-token",
+			expectReqCount: 1,
+			expectReqParams: []map[string]interface{}{
+				{
+					"AuthMethod":  "k8s-auth",
+					"BearerToken": "sa-token",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error-missing-token-file",
+			authLogin: &AuthLoginKubernetes{
+				AuthLoginCommon: AuthLoginCommon{
+					authField:   utils.FieldAuthLoginKubernetes,
+					initialized: true,
+					params: map[string]interface{}{
+						utils.FieldAuthMethod:              "k8s-auth",
+						utils.FieldServiceAccountTokenFile: filepath.Join(t.TempDir(), "missing"),
+					},
+				},
+			},
+			handler: &testLoginHandler{
+				handlerFunc: successHandler,
+			},
+			want:               "",
+			expectReqCount:     0,
+			skipCheckReqParams: true,
+			wantErr:            true,
+		},
+		{
+			name: "error-no-auth-method",
+			authLogin: &AuthLoginKubernetes{
+				AuthLoginCommon: AuthLoginCommon{
+					authField:   utils.FieldAuthLoginKubernetes,
+					initialized: true,
+					params: map[string]interface{}{
+						utils.FieldServiceAccountTokenFile: tokenFile,
+					},
+				},
+			},
+			handler: &testLoginHandler{
+				handlerFunc: successHandler,
+			},
+			want:               "",
+			expectReqCount:     0,
+			skipCheckReqParams: true,
+			wantErr:            true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testAuthLogin(t, tt)
+		})
+	}
+}
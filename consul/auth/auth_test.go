@@ -18,7 +18,7 @@ import (
 
 // expectedRegisteredAuthLogin value should be modified when adding
 // registering/de-registering AuthLogin resources.
-const expectedRegisteredAuthLogin = 1
+const expectedRegisteredAuthLogin = 3
 
 type authLoginTest struct {
 	name               string
@@ -204,7 +204,8 @@ func TestGetAuthLogin_registered(t *testing.T) {
 			raw := map[string]interface{}{
 				field: []interface{}{
 					map[string]interface{}{
-						"auth_method": "test-auth", // Provide required field
+						"auth_method":  "test-auth",  // Provide required field
+						"bearer_token": "test-token", // Required by JWT auth login
 					},
 				},
 			}
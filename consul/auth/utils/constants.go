@@ -67,6 +67,48 @@ const (
 	FieldBearerToken = "bearer_token"
 )
 
+// Kubernetes Authentication Configuration (kubernetes-auth group)
+
+// Environment Variables used for Kubernetes authentication
+const (
+	// EnvVarKubernetesServiceAccountTokenFile is the environment variable for the service account token file
+	EnvVarKubernetesServiceAccountTokenFile = "CONSUL_LOGIN_KUBERNETES_TOKEN_FILE"
+)
+
+// Schema Field Names for Kubernetes authentication configuration
+const (
+	// FieldAuthLoginKubernetes is the field name for Kubernetes authentication login
+	FieldAuthLoginKubernetes = "auth_login_kubernetes"
+	// FieldServiceAccountTokenFile is the field name for the service account token file
+	FieldServiceAccountTokenFile = "service_account_token_file"
+)
+
+// DefaultKubernetesServiceAccountTokenFile is the path of the projected
+// service account token mounted in every pod
+const DefaultKubernetesServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// JWT Authentication Configuration (jwt-auth group)
+
+// Environment Variables used for JWT authentication
+const (
+	// EnvVarTFCWorkloadIdentityToken is the environment variable holding the Terraform Cloud workload identity token
+	EnvVarTFCWorkloadIdentityToken = "TFC_WORKLOAD_IDENTITY_TOKEN"
+)
+
+// Schema Field Names for JWT authentication configuration
+const (
+	// FieldAuthLoginJWT is the field name for JWT authentication login
+	FieldAuthLoginJWT = "auth_login_jwt"
+	// FieldAuthJWT is the field name for the legacy JWT authentication block
+	FieldAuthJWT = "auth_jwt"
+	// FieldBearerTokenFile is the field name for the bearer token file
+	FieldBearerTokenFile = "bearer_token_file"
+	// FieldBearerTokenEnv is the field name for the bearer token environment variable
+	FieldBearerTokenEnv = "bearer_token_env"
+	// FieldUseTFCWorkloadIdentity is the field name for using the Terraform Cloud workload identity token
+	FieldUseTFCWorkloadIdentity = "use_terraform_cloud_workload_identity"
+)
+
 // Default AWS Region used when no region is specified
 const DefaultAWSRegion = "us-east-1"
 
@@ -4,32 +4,155 @@
 package consul
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 
 	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/terraform-provider-consul/consul/auth"
+	"golang.org/x/time/rate"
 )
 
 // Config is configuration defined in the provider block
 type Config struct {
-	Datacenter    string `mapstructure:"datacenter"`
-	Address       string `mapstructure:"address"`
-	Scheme        string `mapstructure:"scheme"`
-	HttpAuth      string `mapstructure:"http_auth"`
-	Token         string `mapstructure:"token"`
-	CAFile        string `mapstructure:"ca_file"`
-	CAPem         string `mapstructure:"ca_pem"`
-	CertFile      string `mapstructure:"cert_file"`
-	CertPEM       string `mapstructure:"cert_pem"`
-	KeyFile       string `mapstructure:"key_file"`
-	KeyPEM        string `mapstructure:"key_pem"`
-	CAPath        string `mapstructure:"ca_path"`
-	InsecureHttps bool   `mapstructure:"insecure_https"`
-	Namespace     string `mapstructure:"namespace"`
+	Datacenter    string        `mapstructure:"datacenter"`
+	Address       string        `mapstructure:"address"`
+	Scheme        string        `mapstructure:"scheme"`
+	HttpAuth      string        `mapstructure:"http_auth"`
+	Token         string        `mapstructure:"token"`
+	CAFile        string        `mapstructure:"ca_file"`
+	CAPem         string        `mapstructure:"ca_pem"`
+	CertFile      string        `mapstructure:"cert_file"`
+	CertPEM       string        `mapstructure:"cert_pem"`
+	KeyFile       string        `mapstructure:"key_file"`
+	KeyPEM        string        `mapstructure:"key_pem"`
+	CAPath        string        `mapstructure:"ca_path"`
+	InsecureHttps bool          `mapstructure:"insecure_https"`
+	TLSServerName string        `mapstructure:"tls_server_name"`
+	TLSMinVersion string        `mapstructure:"tls_min_version"`
+	Namespace     string        `mapstructure:"namespace"`
+	LogoutOnExit  bool          `mapstructure:"logout_on_exit"`
+	Retry         []RetryConfig `mapstructure:"retry"`
+
+	MaxRequestsPerSecond  float64 `mapstructure:"max_requests_per_second"`
+	MaxConcurrentRequests int     `mapstructure:"max_concurrent_requests"`
+
+	TLSCipherSuites []string `mapstructure:"tls_cipher_suites"`
+	LogLevel        string   `mapstructure:"log_level"`
 
 	client *consulapi.Client
+	logger hclog.Logger
+
+	// authLogin is set when the provider logged in using an auth method, it
+	// is used to get a new token when the current one expires.
+	authLogin auth.AuthLogin
+	// tokenLock protects Token and issuedTokens once the provider has been
+	// configured
+	tokenLock sync.RWMutex
+	// issuedTokens are all the tokens the provider got from the auth method,
+	// a request sent with any of them can be retried with the current one.
+	issuedTokens map[string]struct{}
+	// loginLock makes sure only one new login is done when concurrent
+	// requests find that the token expired
+	loginLock sync.Mutex
+
+	// The datacenter of the agent is looked up when no datacenter is set in
+	// the provider, only a successful lookup is kept.
+	agentDatacenterLock sync.Mutex
+	agentDatacenter     string
+}
+
+// getAgentDatacenter returns the datacenter of the agent the provider is
+// talking to.
+func (c *Config) getAgentDatacenter() (string, error) {
+	c.agentDatacenterLock.Lock()
+	defer c.agentDatacenterLock.Unlock()
+
+	if c.agentDatacenter != "" {
+		return c.agentDatacenter, nil
+	}
+
+	// Agent().Self() does not take options, the token from the auth method
+	// must be used if the provider logged in.
+	var info map[string]map[string]interface{}
+	_, err := c.client.Raw().Query("/v1/agent/self", &info, &consulapi.QueryOptions{Token: c.token()})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the datacenter of the agent, the datacenter can be set in the provider configuration instead: %v", err)
+	}
+
+	dc, ok := info["Config"]["Datacenter"].(string)
+	if !ok || dc == "" {
+		return "", fmt.Errorf("failed to get the datacenter of the agent, the datacenter can be set in the provider configuration instead: datacenter not found in the agent configuration")
+	}
+
+	log.Printf("[DEBUG] Using the datacenter of the agent: %q", dc)
+	c.agentDatacenter = dc
+	return dc, nil
+}
+
+// token returns the token to use by default in requests.
+func (c *Config) token() string {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
+
+	return c.Token
+}
+
+// setLoginToken replaces the token to use by default in requests with one
+// obtained from the auth method.
+func (c *Config) setLoginToken(token string) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+
+	if c.issuedTokens == nil {
+		c.issuedTokens = make(map[string]struct{})
+	}
+	c.issuedTokens[token] = struct{}{}
+	c.Token = token
+}
+
+// isLoginToken returns whether token was obtained from the auth method.
+func (c *Config) isLoginToken(token string) bool {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
+
+	_, ok := c.issuedTokens[token]
+	return ok
+}
+
+// refreshLoginToken logs in again using the configured auth method when the
+// expired token is still the current one and returns the token to use.
+func (c *Config) refreshLoginToken(expired string) (string, error) {
+	c.loginLock.Lock()
+	defer c.loginLock.Unlock()
+
+	if token := c.token(); token != expired {
+		// Another request already got a new token
+		return token, nil
+	}
+
+	log.Printf("[DEBUG] The auth method token expired, logging in again using %q", c.authLogin.AuthMethodName())
+	token, err := c.authLogin.Login(c.client)
+	if err != nil {
+		return "", fmt.Errorf("failed to login using auth method %q: %v", c.authLogin.AuthMethodName(), err)
+	}
+
+	c.setLoginToken(token)
+
+	if c.LogoutOnExit {
+		loginTokens.track(c.client, token)
+	}
+
+	return token, nil
 }
 
 // Client returns a new client for accessing consul.
@@ -41,6 +164,17 @@ func (c *Config) Client() (*consulapi.Client, error) {
 	if c.Address != "" {
 		config.Address = c.Address
 	}
+	if strings.HasPrefix(config.Address, "unix://") {
+		// The Consul API client would replace our HTTP client with its own
+		// when given a unix:// address, so we dial the socket ourselves to
+		// keep the transport below.
+		socket := strings.TrimPrefix(config.Address, "unix://")
+		config.Address = socket
+		config.Transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
 	if c.Scheme != "" {
 		config.Scheme = c.Scheme
 	}
@@ -72,11 +206,46 @@ func (c *Config) Client() (*consulapi.Client, error) {
 		}
 		config.TLSConfig.InsecureSkipVerify = c.InsecureHttps
 	}
+	if c.TLSServerName != "" || c.TLSMinVersion != "" || len(c.TLSCipherSuites) > 0 {
+		if config.Scheme != "https" {
+			return nil, fmt.Errorf("tls_server_name, tls_min_version and tls_cipher_suites are meant to be used when scheme is https")
+		}
+	}
+	if c.TLSServerName != "" {
+		if c.InsecureHttps {
+			return nil, fmt.Errorf("tls_server_name cannot be used with insecure_https since the certificate of the server is not verified")
+		}
+		config.TLSConfig.Address = c.TLSServerName
+	}
+	minVersion, cipherSuites, err := parseTLSOptions(c.TLSMinVersion, c.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
 
 	// This is a temporary workaround to add the Content-Type header when
 	// needed until the fix is released in the Consul api client.
+	var rt http.RoundTripper = config.Transport
+	if c.logger != nil {
+		rt = &loggingTransport{RoundTripper: rt, logger: c.logger}
+	}
+	if c.MaxRequestsPerSecond < 0 {
+		return nil, fmt.Errorf("max_requests_per_second must not be negative")
+	}
+	if c.MaxConcurrentRequests < 0 {
+		return nil, fmt.Errorf("max_concurrent_requests must not be negative")
+	}
+	if c.MaxRequestsPerSecond > 0 || c.MaxConcurrentRequests > 0 {
+		rt = newLimitTransport(rt, c.MaxRequestsPerSecond, c.MaxConcurrentRequests)
+	}
+	if len(c.Retry) > 0 {
+		retry, err := newRetryTransport(rt, &c.Retry[0])
+		if err != nil {
+			return nil, err
+		}
+		rt = retry
+	}
 	config.HttpClient = &http.Client{
-		Transport: transport{config.Transport},
+		Transport: transport{rt, c},
 	}
 
 	if config.Transport.TLSClientConfig == nil {
@@ -86,6 +255,13 @@ func (c *Config) Client() (*consulapi.Client, error) {
 			return nil, fmt.Errorf("failed to create http client: %s", err)
 		}
 
+		if minVersion != 0 {
+			tlsClientConfig.MinVersion = minVersion
+		}
+		if len(cipherSuites) > 0 {
+			tlsClientConfig.CipherSuites = cipherSuites
+		}
+
 		config.Transport.TLSClientConfig = tlsClientConfig
 	}
 
@@ -115,12 +291,63 @@ func (c *Config) Client() (*consulapi.Client, error) {
 	return client, nil
 }
 
+// tlsVersions maps the TLS versions accepted by tls_min_version to their
+// value in crypto/tls, using the same names as the Consul agent configuration.
+var tlsVersions = map[string]uint16{
+	"TLSv1_0": tls.VersionTLS10,
+	"TLSv1_1": tls.VersionTLS11,
+	"TLSv1_2": tls.VersionTLS12,
+	"TLSv1_3": tls.VersionTLS13,
+}
+
+// parseTLSOptions returns the minimum TLS version and the cipher suites to
+// use. It returns an error for unknown values and when cipher suites are
+// given with TLS 1.3 since Go does not allow to configure them in this case.
+func parseTLSOptions(minVersion string, cipherSuites []string) (uint16, []uint16, error) {
+	var version uint16
+	if minVersion != "" {
+		v, ok := tlsVersions[minVersion]
+		if !ok {
+			return 0, nil, fmt.Errorf("unsupported tls_min_version %q", minVersion)
+		}
+		version = v
+	}
+
+	if len(cipherSuites) == 0 {
+		return version, nil, nil
+	}
+	if version == tls.VersionTLS13 {
+		return 0, nil, fmt.Errorf("tls_cipher_suites cannot be used when tls_min_version is TLSv1_3, the TLS 1.3 cipher suites are not configurable")
+	}
+
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range cipherSuites {
+		id, ok := known[name]
+		if !ok {
+			return 0, nil, fmt.Errorf("unsupported cipher suite %q in tls_cipher_suites", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return version, ids, nil
+}
+
 // transport adds the Content-Type header to all requests that might need it
 // until we update the API client to a version with
 // https://github.com/hashicorp/consul/pull/10204 at which time we will be able
 // to remove this hack.
+//
+// It also retries once the requests made with a token obtained from an auth
+// method when Consul reports that the token does not exist anymore.
 type transport struct {
 	http.RoundTripper
+
+	config *Config
 }
 
 func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -130,5 +357,139 @@ func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		// actually use the header anyway.
 		req.Header.Add("Content-Type", "application/json")
 	}
-	return t.RoundTripper.RoundTrip(req)
+
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil || !t.loginTokenExpired(req, resp) {
+		return resp, err
+	}
+
+	token, err := t.config.refreshLoginToken(req.Header.Get("X-Consul-Token"))
+	if err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	retry.Header.Set("X-Consul-Token", token)
+
+	return t.RoundTripper.RoundTrip(retry)
+}
+
+// loginTokenExpired returns whether the request failed because it used a
+// token from an auth method that does not exist anymore. The response body is
+// left untouched for the caller when it returns false.
+func (t transport) loginTokenExpired(req *http.Request, resp *http.Response) bool {
+	if t.config == nil || t.config.authLogin == nil || resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+
+	token := req.Header.Get("X-Consul-Token")
+	if token == "" || !t.config.isLoginToken(token) {
+		return false
+	}
+
+	// The request can only be retried if its body can be sent again
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(string(body), "ACL not found")
+}
+
+// limitTransport limits the rate and the number of concurrent requests sent
+// to Consul. Since the provider uses a single client, all the resources share
+// the same budget. It wraps the underlying transport so that each retry and
+// each new login also counts against the limits.
+type limitTransport struct {
+	http.RoundTripper
+
+	limiter *rate.Limiter
+	sem     chan struct{}
+}
+
+func newLimitTransport(rt http.RoundTripper, requestsPerSecond float64, concurrentRequests int) *limitTransport {
+	t := &limitTransport{RoundTripper: rt}
+
+	if requestsPerSecond > 0 {
+		burst := int(math.Ceil(requestsPerSecond))
+		t.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	}
+	if concurrentRequests > 0 {
+		t.sem = make(chan struct{}, concurrentRequests)
+	}
+
+	return t
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Blocking queries can be held by Consul for several minutes, they would
+	// starve the other requests if they used one of the concurrency slots.
+	sem := t.sem
+	if isBlockingQuery(req) {
+		sem = nil
+	}
+
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	release := func() {
+		if sem != nil {
+			<-sem
+		}
+	}
+
+	if t.limiter != nil {
+		if err := t.limiter.Wait(req.Context()); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil || sem == nil {
+		release()
+		return resp, err
+	}
+
+	// The request is still in flight until its body has been read
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// isBlockingQuery returns whether req is a blocking query, that Consul only
+// answers once the result changes or the wait time elapses.
+func isBlockingQuery(req *http.Request) bool {
+	query := req.URL.Query()
+	return query.Get("index") != "" || query.Get("wait") != ""
+}
+
+// releaseOnClose calls release once the body of a response is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
 }
@@ -0,0 +1,519 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+// fakeExpiringTokenServer issues a new token on each login and only accepts
+// the latest one.
+type fakeExpiringTokenServer struct {
+	mu     sync.Mutex
+	logins int
+	bodies []string
+}
+
+func (s *fakeExpiringTokenServer) currentToken() string {
+	return fmt.Sprintf("token-%d", s.logins)
+}
+
+func (s *fakeExpiringTokenServer) handler(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.URL.Path == "/v1/acl/login" {
+		s.logins++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"SecretID": s.currentToken(),
+		})
+		return
+	}
+
+	if req.Header.Get("X-Consul-Token") != s.currentToken() {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("ACL not found"))
+		return
+	}
+
+	b, _ := io.ReadAll(req.Body)
+	s.bodies = append(s.bodies, string(b))
+	_, _ = w.Write([]byte("true"))
+}
+
+func (s *fakeExpiringTokenServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The next login will return a new token, making the current one invalid
+	s.logins++
+}
+
+func TestTransport_refreshLoginToken(t *testing.T) {
+	fake := &fakeExpiringTokenServer{}
+	server := httptest.NewServer(http.HandlerFunc(fake.handler))
+	t.Cleanup(server.Close)
+
+	p := Provider().(*schema.Provider)
+	raw := map[string]interface{}{
+		"address":    strings.TrimPrefix(server.URL, "http://"),
+		"datacenter": "dc1",
+		"auth_login_jwt": []interface{}{
+			map[string]interface{}{
+				"auth_method":  "jwt",
+				"bearer_token": "jwt-token",
+			},
+		},
+	}
+	if err := p.Configure(terraform.NewResourceConfigRaw(raw)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := p.Meta().(*Config)
+	if config.token() != "token-1" {
+		t.Fatalf("expected token-1, got %q", config.token())
+	}
+
+	fake.expire()
+
+	d := schema.TestResourceDataRaw(t, map[string]*schema.Schema{}, map[string]interface{}{})
+	_, wOpts := getOptions(d, config)
+	_, err := config.client.KV().Put(&consulapi.KVPair{Key: "foo", Value: []byte("bar")}, wOpts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if config.token() != "token-3" {
+		t.Fatalf("expected the token to be refreshed, got %q", config.token())
+	}
+	if len(fake.bodies) != 1 || fake.bodies[0] != "bar" {
+		t.Fatalf("expected the request to be retried with its body, got %v", fake.bodies)
+	}
+}
+
+func TestTransport_refreshLoginTokenConcurrent(t *testing.T) {
+	fake := &fakeExpiringTokenServer{}
+	server := httptest.NewServer(http.HandlerFunc(fake.handler))
+	t.Cleanup(server.Close)
+
+	p := Provider().(*schema.Provider)
+	raw := map[string]interface{}{
+		"address":    strings.TrimPrefix(server.URL, "http://"),
+		"datacenter": "dc1",
+		"auth_login_jwt": []interface{}{
+			map[string]interface{}{
+				"auth_method":  "jwt",
+				"bearer_token": "jwt-token",
+			},
+		},
+	}
+	if err := p.Configure(terraform.NewResourceConfigRaw(raw)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config := p.Meta().(*Config)
+
+	// All the requests are sent with the expired token, only one of them
+	// must log in again and all of them must be retried with the new token
+	d := schema.TestResourceDataRaw(t, map[string]*schema.Schema{}, map[string]interface{}{})
+	_, wOpts := getOptions(d, config)
+	fake.expire()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := config.client.KV().Put(&consulapi.KVPair{Key: fmt.Sprintf("foo-%d", i), Value: []byte("bar")}, wOpts)
+			if err != nil {
+				t.Errorf("err: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// A request sent with the expired token that completes after the token
+	// was refreshed is retried too
+	_, err := config.client.KV().Put(&consulapi.KVPair{Key: "late", Value: []byte("bar")}, wOpts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.logins != 3 {
+		t.Fatalf("expected a single new login, got %d logins", fake.logins-1)
+	}
+	if len(fake.bodies) != 11 {
+		t.Fatalf("expected all the requests to succeed, got %d", len(fake.bodies))
+	}
+}
+
+func TestTransport_noRefreshWithoutAuthLogin(t *testing.T) {
+	fake := &fakeExpiringTokenServer{}
+	server := httptest.NewServer(http.HandlerFunc(fake.handler))
+	t.Cleanup(server.Close)
+
+	p := Provider().(*schema.Provider)
+	raw := map[string]interface{}{
+		"address":    strings.TrimPrefix(server.URL, "http://"),
+		"datacenter": "dc1",
+		"token":      "static-token",
+	}
+	if err := p.Configure(terraform.NewResourceConfigRaw(raw)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := p.Meta().(*Config)
+	d := schema.TestResourceDataRaw(t, map[string]*schema.Schema{}, map[string]interface{}{})
+	_, wOpts := getOptions(d, config)
+	_, err := config.client.KV().Put(&consulapi.KVPair{Key: "foo", Value: []byte("bar")}, wOpts)
+	if err == nil || !strings.Contains(err.Error(), "ACL not found") {
+		t.Fatalf("expected ACL not found error, got %v", err)
+	}
+	if fake.logins != 0 {
+		t.Fatalf("expected no login, got %d", fake.logins)
+	}
+}
+
+func TestLimitTransport_concurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newLimitTransport(http.DefaultTransport, 0, 2)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight != 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", maxInFlight)
+	}
+}
+
+func TestLimitTransport_blockingQuery(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("index") != "" {
+			<-unblock
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(unblock) })
+
+	client := &http.Client{Transport: newLimitTransport(http.DefaultTransport, 0, 1)}
+
+	// The blocking query does not hold the only concurrency slot
+	go func() {
+		resp, err := client.Get(server.URL + "/v1/kv/foo?index=42&wait=1s")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/kv/foo", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+}
+
+func TestLimitTransport_rate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newLimitTransport(http.DefaultTransport, 50, 0)}
+
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp.Body.Close()
+	}
+
+	// The first 50 requests are allowed by the burst, the next 10 must be
+	// spread over 200ms
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expected the requests to be rate limited, took %s", elapsed)
+	}
+}
+
+func TestResourceProvider_limitsValidation(t *testing.T) {
+	p := Provider().(*schema.Provider)
+	raw := map[string]interface{}{
+		"address":                 "127.0.0.1:8500",
+		"max_requests_per_second": -1,
+	}
+	_, errs := p.Validate(terraform.NewResourceConfigRaw(raw))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "max_requests_per_second") {
+		t.Fatalf("expected a validation error, got %v", errs)
+	}
+
+	err := p.Configure(terraform.NewResourceConfigRaw(raw))
+	if err == nil || !strings.Contains(err.Error(), "max_requests_per_second must not be negative") {
+		t.Fatalf("expected an error, got %v", err)
+	}
+}
+
+func TestConfig_unixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "consul")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "consul.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	requests := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("true"))
+	}))
+	server.Listener = ln
+	server.Start()
+	t.Cleanup(server.Close)
+
+	config := &Config{
+		Address: "unix://" + socket,
+		Retry: []RetryConfig{
+			{MaxAttempts: 2, MinBackoff: "1ms", MaxBackoff: "1ms"},
+		},
+	}
+	client, err := config.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = client.KV().Put(&consulapi.KVPair{Key: "foo", Value: []byte("bar")}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The first request is retried by our transport, this makes sure it is
+	// still used with the socket
+	if requests != 2 {
+		t.Fatalf("expected the transport to be used, got %d requests", requests)
+	}
+}
+
+func TestConfig_http2(t *testing.T) {
+	var proto int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proto = req.ProtoMajor
+		_, _ = w.Write([]byte("true"))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	config := &Config{
+		Address: strings.TrimPrefix(server.URL, "https://"),
+		Scheme:  "https",
+		CAPem:   string(caPem),
+	}
+	client, err := config.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = client.KV().Put(&consulapi.KVPair{Key: "foo", Value: []byte("bar")}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if proto != 2 {
+		t.Fatalf("expected HTTP/2 to be used, got HTTP/%d", proto)
+	}
+}
+
+func testTLSServer(t *testing.T, maxVersion uint16) (string, string) {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("true"))
+	}))
+	server.TLS = &tls.Config{MaxVersion: maxVersion}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return server.URL, string(caPem)
+}
+
+func TestConfig_TLSOptions(t *testing.T) {
+	serverURL, caPem := testTLSServer(t, tls.VersionTLS12)
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(serverURL, "https://"))
+
+	testCases := map[string]struct {
+		config      *Config
+		expectError string
+	}{
+		"hostname-mismatch": {
+			config: &Config{
+				Address: "localhost:" + port,
+			},
+			expectError: "certificate is valid for example.com",
+		},
+		"server-name": {
+			config: &Config{
+				Address:       "localhost:" + port,
+				TLSServerName: "example.com",
+			},
+		},
+		"min-version-too-high": {
+			config: &Config{
+				Address:       "localhost:" + port,
+				TLSServerName: "example.com",
+				TLSMinVersion: "TLSv1_3",
+			},
+			expectError: "protocol version not supported",
+		},
+		"cipher-suites": {
+			config: &Config{
+				Address:         "localhost:" + port,
+				TLSServerName:   "example.com",
+				TLSMinVersion:   "TLSv1_2",
+				TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.config.Scheme = "https"
+			tc.config.CAPem = caPem
+
+			client, err := tc.config.Client()
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			_, err = client.KV().Put(&consulapi.KVPair{Key: "foo", Value: []byte("bar")}, nil)
+			if tc.expectError == "" {
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+				t.Fatalf("expected error containing %q, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestConfig_TLSOptionsValidation(t *testing.T) {
+	testCases := map[string]struct {
+		config      *Config
+		expectError string
+	}{
+		"not-https": {
+			config: &Config{
+				Scheme:        "http",
+				TLSServerName: "server.dc1.consul",
+			},
+			expectError: "tls_server_name, tls_min_version and tls_cipher_suites are meant to be used when scheme is https",
+		},
+		"insecure": {
+			config: &Config{
+				Scheme:        "https",
+				InsecureHttps: true,
+				TLSServerName: "server.dc1.consul",
+			},
+			expectError: "tls_server_name cannot be used with insecure_https",
+		},
+		"unknown-version": {
+			config: &Config{
+				Scheme:        "https",
+				TLSMinVersion: "TLSv2",
+			},
+			expectError: `unsupported tls_min_version "TLSv2"`,
+		},
+		"unknown-cipher-suite": {
+			config: &Config{
+				Scheme:          "https",
+				TLSCipherSuites: []string{"TLS_FOO"},
+			},
+			expectError: `unsupported cipher suite "TLS_FOO"`,
+		},
+		"cipher-suites-tls13": {
+			config: &Config{
+				Scheme:          "https",
+				TLSMinVersion:   "TLSv1_3",
+				TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			},
+			expectError: "tls_cipher_suites cannot be used when tls_min_version is TLSv1_3",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := tc.config.Client()
+			if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+				t.Fatalf("expected error containing %q, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
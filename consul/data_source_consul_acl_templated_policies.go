@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func dataSourceConsulACLTemplatedPolicies() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceConsulACLTemplatedPoliciesRead,
+
+		Description: "The `consul_acl_templated_policies` data source returns the [templated policies](https://developer.hashicorp.com/consul/docs/security/acl/acl-policies#templated-policies) available in the cluster, that can be attached to tokens and roles with their `templated_policies` blocks.",
+
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The namespace to lookup the templated policies.",
+			},
+			"partition": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The partition to lookup the templated policies.",
+			},
+
+			// Out parameters
+			"templated_policies": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The templated policies, sorted by name.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"template_name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the templated policy.",
+						},
+						"description": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The description of the templated policy.",
+						},
+						"schema": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The JSON schema of the variables of the templated policy, empty when it does not take any.",
+						},
+						"template": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The template of the rules of the policy.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceConsulACLTemplatedPoliciesRead(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, _ := getClient(d, meta)
+
+	entries, _, err := client.ACL().TemplatedPolicyList(qOpts)
+	if err != nil {
+		return fmt.Errorf("failed to list templated policies: %v", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	templatedPolicies := make([]interface{}, len(names))
+	for i, name := range names {
+		tp := entries[name]
+		templatedPolicies[i] = map[string]interface{}{
+			"template_name": name,
+			"description":   tp.Description,
+			"schema":        tp.Schema,
+			"template":      tp.Template,
+		}
+	}
+
+	d.SetId("templated-policies")
+
+	sw := newStateWriter(d)
+	sw.set("templated_policies", templatedPolicies)
+
+	return sw.error()
+}
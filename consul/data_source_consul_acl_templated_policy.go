@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"fmt"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func dataSourceConsulACLTemplatedPolicy() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceConsulACLTemplatedPolicyRead,
+
+		Description: "The `consul_acl_templated_policy` data source returns a [templated policy](https://developer.hashicorp.com/consul/docs/security/acl/acl-policies#templated-policies) and a preview of the rules it expands to for the given variables, so that they can be reviewed before attaching it to a token or a role.",
+
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"template_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the templated policy, for example `builtin/service`.",
+			},
+			"template_variables": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "The variables used to render the preview of the rules.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The name of node, workload identity or service.",
+						},
+					},
+				},
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The namespace to lookup the templated policy.",
+			},
+			"partition": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The partition to lookup the templated policy.",
+			},
+
+			// Out parameters
+			"description": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The description of the templated policy.",
+			},
+			"schema": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The JSON schema of the variables of the templated policy, empty when it does not take any.",
+			},
+			"template": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The template of the rules of the policy.",
+			},
+			"rules": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The rules the templated policy expands to. It is only rendered when `template_variables` is set or when the templated policy does not take any variable, and is empty otherwise.",
+			},
+		},
+	}
+}
+
+func dataSourceConsulACLTemplatedPolicyRead(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, wOpts := getClient(d, meta)
+	name := d.Get("template_name").(string)
+
+	tp, _, err := client.ACL().TemplatedPolicyReadByName(name, qOpts)
+	if err != nil {
+		return fmt.Errorf("failed to read templated policy %q: %v", name, err)
+	}
+	if tp == nil {
+		return fmt.Errorf("could not find templated policy %q", name)
+	}
+
+	// The preview fails when the variables required by the templated policy
+	// are missing, in which case only its definition is returned
+	var rules string
+	variables := getTemplatedPolicyVariables(d)
+	if variables != nil || tp.Schema == "" {
+		preview, _, err := client.ACL().TemplatedPolicyPreview(&consulapi.ACLTemplatedPolicy{
+			TemplateName:      name,
+			TemplateVariables: variables,
+		}, wOpts)
+		if err != nil {
+			return fmt.Errorf("failed to preview templated policy %q: %v", name, err)
+		}
+		rules = preview.Rules
+	}
+
+	d.SetId(tp.TemplateName)
+
+	sw := newStateWriter(d)
+	sw.set("description", tp.Description)
+	sw.set("schema", tp.Schema)
+	sw.set("template", tp.Template)
+	sw.set("rules", rules)
+
+	return sw.error()
+}
+
+func getTemplatedPolicyVariables(d *schema.ResourceData) *consulapi.ACLTemplatedPolicyVariables {
+	raw := d.Get("template_variables").([]interface{})
+	if len(raw) == 0 {
+		return nil
+	}
+
+	variables := &consulapi.ACLTemplatedPolicyVariables{}
+	if m, ok := raw[0].(map[string]interface{}); ok {
+		variables.Name = m["name"].(string)
+	}
+	return variables
+}
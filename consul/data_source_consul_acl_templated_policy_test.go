@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccDataACLTemplatedPolicies_basic(t *testing.T) {
+	providers, _ := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers: providers,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataACLTemplatedPoliciesConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("data.consul_acl_templated_policies.all", "templated_policies.#", regexp.MustCompile(`^[1-9][0-9]*$`)),
+					resource.TestCheckResourceAttr("data.consul_acl_templated_policies.all", "templated_policies.0.template_name", "builtin/api-gateway"),
+					resource.TestCheckResourceAttrSet("data.consul_acl_templated_policies.all", "templated_policies.0.description"),
+					resource.TestCheckResourceAttrSet("data.consul_acl_templated_policies.all", "templated_policies.0.template"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataACLTemplatedPolicy_basic(t *testing.T) {
+	providers, _ := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers: providers,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccDataACLTemplatedPolicyConfigNotFound,
+				ExpectError: regexp.MustCompile(`templated policy "builtin/not-found"`),
+			},
+			{
+				Config: testAccDataACLTemplatedPolicyConfig,
+				Check: resource.ComposeTestCheckFunc(
+					// The variables are given, the rules are rendered
+					resource.TestCheckResourceAttr("data.consul_acl_templated_policy.service", "id", "builtin/service"),
+					resource.TestCheckResourceAttrSet("data.consul_acl_templated_policy.service", "description"),
+					resource.TestCheckResourceAttrSet("data.consul_acl_templated_policy.service", "schema"),
+					resource.TestMatchResourceAttr("data.consul_acl_templated_policy.service", "template", regexp.MustCompile(`{{.Name}}`)),
+					resource.TestMatchResourceAttr("data.consul_acl_templated_policy.service", "rules", regexp.MustCompile(`service "web"`)),
+
+					// The variables are missing, only the definition is returned
+					resource.TestCheckResourceAttrSet("data.consul_acl_templated_policy.service_no_variables", "schema"),
+					resource.TestCheckResourceAttr("data.consul_acl_templated_policy.service_no_variables", "rules", ""),
+
+					// No variables are needed
+					resource.TestCheckResourceAttr("data.consul_acl_templated_policy.dns", "schema", ""),
+					resource.TestMatchResourceAttr("data.consul_acl_templated_policy.dns", "rules", regexp.MustCompile(`node_prefix ""`)),
+				),
+			},
+		},
+	})
+}
+
+const testAccDataACLTemplatedPoliciesConfig = `
+data "consul_acl_templated_policies" "all" {}
+`
+
+const testAccDataACLTemplatedPolicyConfigNotFound = `
+data "consul_acl_templated_policy" "test" {
+  template_name = "builtin/not-found"
+}
+`
+
+const testAccDataACLTemplatedPolicyConfig = `
+data "consul_acl_templated_policy" "service" {
+  template_name = "builtin/service"
+
+  template_variables {
+    name = "web"
+  }
+}
+
+data "consul_acl_templated_policy" "service_no_variables" {
+  template_name = "builtin/service"
+}
+
+data "consul_acl_templated_policy" "dns" {
+  template_name = "builtin/dns"
+}
+`
@@ -64,12 +64,7 @@ func dataSourceConsulACLTokenSecretIDRead(d *schema.ResourceData, meta interface
 
 	d.SetId(accessorID)
 	if v, ok := d.GetOk("pgp_key"); ok {
-		pgpKey := v.(string)
-		encryptionKey, err := encryption.RetrieveGPGKey(pgpKey)
-		if err != nil {
-			return err
-		}
-		_, encrypted, err := encryption.EncryptValue(encryptionKey, aclToken.SecretID, "ACL Token secret ID")
+		encrypted, err := encryptACLTokenSecretID(v.(string), aclToken.SecretID)
 		if err != nil {
 			return err
 		}
@@ -96,3 +91,18 @@ func dataSourceConsulACLTokenSecretIDRead(d *schema.ResourceData, meta interface
 	}
 	return nil
 }
+
+// encryptACLTokenSecretID encrypts the secret ID of a token with the given
+// PGP key, or the key of the given Keybase user when it is formatted as
+// keybase:<username>, and returns it base64-encoded.
+func encryptACLTokenSecretID(pgpKey, secretID string) (string, error) {
+	encryptionKey, err := encryption.RetrieveGPGKey(pgpKey)
+	if err != nil {
+		return "", err
+	}
+	_, encrypted, err := encryption.EncryptValue(encryptionKey, secretID, "ACL Token secret ID")
+	if err != nil {
+		return "", err
+	}
+	return encrypted, nil
+}
@@ -4,6 +4,8 @@
 package consul
 
 import (
+	"encoding/base64"
+
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
@@ -61,6 +63,14 @@ func dataSourceConsulKeyPrefix() *schema.Resource {
 				},
 			},
 
+			"var_base64": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
 			"subkeys": {
 				Type:     schema.TypeMap,
 				Computed: true,
@@ -69,6 +79,14 @@ func dataSourceConsulKeyPrefix() *schema.Resource {
 				},
 			},
 
+			"subkeys_base64": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
 			"namespace": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -88,6 +106,7 @@ func dataSourceConsulKeyPrefixRead(d *schema.ResourceData, meta interface{}) err
 	pathPrefix := d.Get("path_prefix").(string)
 
 	vars := make(map[string]string)
+	varsBase64 := make(map[string]string)
 
 	keys := d.Get("subkey").(*schema.Set).List()
 	for _, raw := range keys {
@@ -104,11 +123,15 @@ func dataSourceConsulKeyPrefixRead(d *schema.ResourceData, meta interface{}) err
 
 		value = attributeValue(sub, value)
 		vars[key] = value
+		varsBase64[key] = base64.StdEncoding.EncodeToString([]byte(value))
 	}
 
 	if err := d.Set("var", vars); err != nil {
 		return err
 	}
+	if err := d.Set("var_base64", varsBase64); err != nil {
+		return err
+	}
 
 	if len(keys) <= 0 {
 		pairs, err := keyClient.GetUnderPrefix(pathPrefix)
@@ -116,11 +139,14 @@ func dataSourceConsulKeyPrefixRead(d *schema.ResourceData, meta interface{}) err
 			return err
 		}
 		subKeys := map[string]string{}
+		subKeysBase64 := map[string]string{}
 		for _, pair := range pairs {
 			subKey := pair.Key[len(pathPrefix):]
 			subKeys[subKey] = string(pair.Value)
+			subKeysBase64[subKey] = base64.StdEncoding.EncodeToString(pair.Value)
 		}
 		d.Set("subkeys", subKeys)
+		d.Set("subkeys_base64", subKeysBase64)
 	}
 
 	// Store the datacenter on this resource, which can be helpful for reference
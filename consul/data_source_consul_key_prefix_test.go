@@ -4,11 +4,14 @@
 package consul
 
 import (
+	"encoding/base64"
 	"fmt"
+	"reflect"
 	"regexp"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
 
@@ -191,3 +194,41 @@ data "consul_key_prefix" "dc2" {
 	path_prefix = consul_key_prefix.dc2.path_prefix
 }
 `
+
+func TestDataConsulKeyPrefix_base64(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	// Not a valid UTF-8 string
+	binary := []byte{0x30, 0x82, 0x01, 0x0a, 0xff, 0xfe}
+	kv.set("prefix_test/cert.der", binary, 0)
+	kv.set("prefix_test/name", []byte("foo"), 0)
+
+	expected := map[string]interface{}{
+		"cert.der": base64.StdEncoding.EncodeToString(binary),
+		"name":     "Zm9v",
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceConsulKeyPrefix().Schema, map[string]interface{}{
+		"path_prefix": "prefix_test/",
+	})
+	if err := dataSourceConsulKeyPrefixRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := d.Get("subkeys_base64"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceConsulKeyPrefix().Schema, map[string]interface{}{
+		"path_prefix": "prefix_test/",
+		"subkey": []interface{}{
+			map[string]interface{}{"name": "cert.der", "path": "cert.der"},
+			map[string]interface{}{"name": "name", "path": "name"},
+		},
+	})
+	if err := dataSourceConsulKeyPrefixRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := d.Get("var_base64"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+func dataSourceConsulKeyTree() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceConsulKeyTreeRead,
+
+		Description: "The `consul_key_tree` data source reads all the keys under a prefix of the Consul KV store and returns them as a nested object whose structure follows the paths of the keys, as well as a flattened map of their values.",
+
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"path_prefix": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The prefix of the keys to read. Only the keys whose path continues with the separator after the prefix are read, so `app` matches `app/name` but not `application/name`.",
+			},
+			"separator": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "/",
+				Description:  "The separator used to split the paths of the keys into the levels of the tree.",
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"decode_json": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether the values that are valid JSON documents should be decoded in `tree`. The other values are kept as strings.",
+			},
+			"keys_only": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Only list the paths of the keys without reading their values, to limit the size of the response for very large trees. When set, the leaves of `tree` are `null`, `flattened` is empty and the `flags` and `modify_index` of the keys are not reported.",
+			},
+			"datacenter": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The datacenter to use. This overrides the agent's default datacenter and the datacenter in the provider setup.",
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The namespace to lookup the keys.",
+			},
+			"partition": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The partition to lookup the keys.",
+			},
+
+			// Out parameters
+			"tree": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The keys as a JSON-encoded nested object, use `jsondecode()` to access it. The key stored at `path_prefix` itself, if any, is not included.",
+			},
+			"flattened": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The values of the keys indexed by their path relative to `path_prefix`. The folders, whose path ends with the separator, are not included.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"keys": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The keys found under `path_prefix`, except the folders.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The path of the key relative to `path_prefix`.",
+						},
+						"flags": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The flags of the key.",
+						},
+						"modify_index": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The index of the last modification of the key.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceConsulKeyTreeRead(d *schema.ResourceData, meta interface{}) error {
+	keyClient := newKeyClient(d, meta)
+
+	pathPrefix := d.Get("path_prefix").(string)
+	separator := d.Get("separator").(string)
+	decodeJSON := d.Get("decode_json").(bool)
+
+	tree := map[string]interface{}{}
+	flattened := map[string]string{}
+	keys := []interface{}{}
+
+	if d.Get("keys_only").(bool) {
+		paths, err := keyClient.GetKeysUnderPrefix(pathPrefix)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			subKey := keyTreePath(pathPrefix, separator, path)
+			if subKey == "" {
+				continue
+			}
+			if err := insertKeyTree(tree, separator, subKey, nil); err != nil {
+				return err
+			}
+			if strings.HasSuffix(subKey, separator) {
+				continue
+			}
+			keys = append(keys, map[string]interface{}{
+				"path": subKey,
+			})
+		}
+	} else {
+		pairs, err := keyClient.GetUnderPrefix(pathPrefix)
+		if err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			subKey := keyTreePath(pathPrefix, separator, pair.Key)
+			if subKey == "" {
+				continue
+			}
+
+			var value interface{} = string(pair.Value)
+			if decodeJSON {
+				var decoded interface{}
+				if err := json.Unmarshal(pair.Value, &decoded); err == nil {
+					value = decoded
+				}
+			}
+			if err := insertKeyTree(tree, separator, subKey, value); err != nil {
+				return err
+			}
+
+			// The folders only appear in the tree
+			if strings.HasSuffix(subKey, separator) {
+				continue
+			}
+
+			flattened[subKey] = string(pair.Value)
+			keys = append(keys, map[string]interface{}{
+				"path":         subKey,
+				"flags":        int(pair.Flags),
+				"modify_index": int(pair.ModifyIndex),
+			})
+		}
+	}
+
+	encoded, err := json.Marshal(tree)
+	if err != nil {
+		return fmt.Errorf("failed to encode the tree of keys under '%s': %v", pathPrefix, err)
+	}
+
+	d.SetId(fmt.Sprintf("%s-%s", keyClient.qOpts.Datacenter, pathPrefix))
+
+	sw := newStateWriter(d)
+	sw.set("datacenter", keyClient.qOpts.Datacenter)
+	sw.set("tree", string(encoded))
+	sw.set("flattened", flattened)
+	sw.set("keys", keys)
+
+	return sw.error()
+}
+
+// keyTreePath returns the path of a key relative to pathPrefix, without the
+// leading separator. The prefix must end at a separator so that the prefix
+// "app" does not match the key "application/name", an empty string is returned
+// for the keys that are not under the prefix and for the prefix itself.
+func keyTreePath(pathPrefix, separator, path string) string {
+	if !strings.HasPrefix(path, pathPrefix) {
+		return ""
+	}
+	subKey := path[len(pathPrefix):]
+	if pathPrefix == "" || strings.HasSuffix(pathPrefix, separator) {
+		return subKey
+	}
+	if !strings.HasPrefix(subKey, separator) {
+		return ""
+	}
+	return subKey[len(separator):]
+}
+
+// insertKeyTree stores value in tree at the position given by the segments of
+// path. A path ending with the separator is a folder and only creates the
+// intermediate objects.
+func insertKeyTree(tree map[string]interface{}, separator, path string, value interface{}) error {
+	segments := strings.Split(path, separator)
+	folder := strings.HasSuffix(path, separator)
+	if folder {
+		segments = segments[:len(segments)-1]
+	}
+
+	node := tree
+	for i, segment := range segments {
+		current, exists := node[segment]
+
+		if i == len(segments)-1 && !folder {
+			if _, ok := current.(map[string]interface{}); exists && ok {
+				return keyTreeConflictError(path)
+			}
+			node[segment] = value
+			return nil
+		}
+
+		if !exists {
+			current = map[string]interface{}{}
+			node[segment] = current
+		}
+		child, ok := current.(map[string]interface{})
+		if !ok {
+			return keyTreeConflictError(strings.Join(segments[:i+1], separator))
+		}
+		node = child
+	}
+
+	return nil
+}
+
+func keyTreeConflictError(path string) error {
+	return fmt.Errorf("key '%s' has a value and is also the prefix of other keys, it cannot be represented in the tree", path)
+}
@@ -0,0 +1,210 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func TestDataConsulKeyTree(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	kv.set("tree_test/", nil, 0)
+	kv.set("tree_test/app/config", []byte(`{"replicas": 3}`), 0)
+	kv.set("tree_test/app/name", []byte("web"), 42)
+	kv.set("tree_test/db/", nil, 0)
+	kv.set("tree_test/version", []byte("1.2.3"), 0)
+
+	d := schema.TestResourceDataRaw(t, dataSourceConsulKeyTree().Schema, map[string]interface{}{
+		"path_prefix": "tree_test/",
+	})
+	if err := dataSourceConsulKeyTreeRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expectedTree := `{"app":{"config":"{\"replicas\": 3}","name":"web"},"db":{},"version":"1.2.3"}`
+	if got := d.Get("tree"); got != expectedTree {
+		t.Fatalf("expected %s, got %s", expectedTree, got)
+	}
+	// The folders are only part of the tree
+	expectedFlattened := map[string]interface{}{
+		"app/config": `{"replicas": 3}`,
+		"app/name":   "web",
+		"version":    "1.2.3",
+	}
+	if got := d.Get("flattened"); !reflect.DeepEqual(got, expectedFlattened) {
+		t.Fatalf("expected %v, got %v", expectedFlattened, got)
+	}
+	if got := d.Get("keys.#"); got != 3 {
+		t.Fatalf("expected 3 keys, got %v", got)
+	}
+	if got := d.Get("keys.1.path"); got != "app/name" {
+		t.Fatalf("unexpected path %v", got)
+	}
+	if got := d.Get("keys.1.flags"); got != 42 {
+		t.Fatalf("unexpected flags %v", got)
+	}
+	if got := d.Get("keys.1.modify_index"); got != 3 {
+		t.Fatalf("unexpected modify_index %v", got)
+	}
+
+	// Decode the JSON values
+	d = schema.TestResourceDataRaw(t, dataSourceConsulKeyTree().Schema, map[string]interface{}{
+		"path_prefix": "tree_test/",
+		"decode_json": true,
+	})
+	if err := dataSourceConsulKeyTreeRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expectedTree = `{"app":{"config":{"replicas":3},"name":"web"},"db":{},"version":"1.2.3"}`
+	if got := d.Get("tree"); got != expectedTree {
+		t.Fatalf("expected %s, got %s", expectedTree, got)
+	}
+
+	// Only list the keys
+	d = schema.TestResourceDataRaw(t, dataSourceConsulKeyTree().Schema, map[string]interface{}{
+		"path_prefix": "tree_test/",
+		"keys_only":   true,
+	})
+	if err := dataSourceConsulKeyTreeRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expectedTree = `{"app":{"config":null,"name":null},"db":{},"version":null}`
+	if got := d.Get("tree"); got != expectedTree {
+		t.Fatalf("expected %s, got %s", expectedTree, got)
+	}
+	if got := d.Get("flattened"); len(got.(map[string]interface{})) != 0 {
+		t.Fatalf("expected no values, got %v", got)
+	}
+	if got := d.Get("keys.#"); got != 3 {
+		t.Fatalf("expected 3 keys, got %v", got)
+	}
+	if got := d.Get("keys.0.modify_index"); got != 0 {
+		t.Fatalf("unexpected modify_index %v", got)
+	}
+}
+
+func TestDataConsulKeyTree_separator(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	kv.set("tree_test.app.name", []byte("web"), 0)
+	kv.set("tree_test.app.port", []byte("8080"), 0)
+
+	d := schema.TestResourceDataRaw(t, dataSourceConsulKeyTree().Schema, map[string]interface{}{
+		"path_prefix": "tree_test",
+		"separator":   ".",
+	})
+	if err := dataSourceConsulKeyTreeRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `{"app":{"name":"web","port":"8080"}}`
+	if got := d.Get("tree"); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestDataConsulKeyTree_prefixBoundary(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	kv.set("app", []byte("root"), 0)
+	kv.set("app/name", []byte("web"), 0)
+	kv.set("application/name", []byte("other"), 0)
+
+	d := schema.TestResourceDataRaw(t, dataSourceConsulKeyTree().Schema, map[string]interface{}{
+		"path_prefix": "app",
+	})
+	if err := dataSourceConsulKeyTreeRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{"name": "web"}
+	if got := d.Get("flattened"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := d.Get("tree"); got != `{"name":"web"}` {
+		t.Fatalf("unexpected tree %s", got)
+	}
+}
+
+func TestKeyTreePath(t *testing.T) {
+	testCases := []struct {
+		prefix, separator, path, expected string
+	}{
+		{"app/", "/", "app/name", "name"},
+		{"app", "/", "app/name", "name"},
+		{"app", "/", "application/name", ""},
+		{"app", "/", "app", ""},
+		{"app/", "/", "app/", ""},
+		{"", "/", "app/name", "app/name"},
+		{"app", "::", "app::name", "name"},
+		{"app", "::", "app:name", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := keyTreePath(tc.prefix, tc.separator, tc.path); got != tc.expected {
+			t.Errorf("keyTreePath(%q, %q, %q) = %q, expected %q", tc.prefix, tc.separator, tc.path, got, tc.expected)
+		}
+	}
+}
+
+func TestDataConsulKeyTree_conflict(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	kv.set("tree_test/app", []byte("web"), 0)
+	kv.set("tree_test/app/name", []byte("web"), 0)
+
+	d := schema.TestResourceDataRaw(t, dataSourceConsulKeyTree().Schema, map[string]interface{}{
+		"path_prefix": "tree_test/",
+	})
+	err := dataSourceConsulKeyTreeRead(d, config)
+	if err == nil || !regexp.MustCompile(`key 'app' has a value and is also the prefix of other keys`).MatchString(err.Error()) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAccDataConsulKeyTree_basic(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers: providers,
+		PreCheck: func() {
+			for k, v := range map[string]string{
+				"tree_test/app/name": "web",
+				"tree_test/app/port": "8080",
+			} {
+				if _, err := client.KV().Put(&consulapi.KVPair{Key: k, Value: []byte(v)}, nil); err != nil {
+					t.Fatalf("err: %v", err)
+				}
+			}
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataConsulKeyTreeConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.consul_key_tree.test", "datacenter", "dc1"),
+					resource.TestCheckResourceAttr("data.consul_key_tree.test", "tree", `{"app":{"name":"web","port":8080}}`),
+					resource.TestCheckResourceAttr("data.consul_key_tree.test", "flattened.%", "2"),
+					resource.TestCheckResourceAttr("data.consul_key_tree.test", "flattened.app/port", "8080"),
+					resource.TestCheckResourceAttr("data.consul_key_tree.test", "keys.#", "2"),
+					resource.TestCheckResourceAttr("data.consul_key_tree.test", "keys.0.path", "app/name"),
+					resource.TestCheckResourceAttrSet("data.consul_key_tree.test", "keys.0.modify_index"),
+				),
+			},
+		},
+	})
+}
+
+const testAccDataConsulKeyTreeConfig = `
+data "consul_key_tree" "test" {
+  path_prefix = "tree_test/"
+  decode_json = true
+}
+`
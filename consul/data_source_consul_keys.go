@@ -4,6 +4,7 @@
 package consul
 
 import (
+	"encoding/base64"
 	"fmt"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
@@ -73,6 +74,15 @@ func dataSourceConsulKeys() *schema.Resource {
 				},
 			},
 
+			"var_base64": {
+				Type:        schema.TypeMap,
+				Description: "For each name given, the corresponding attribute has the base64-encoded value of the key. This must be used instead of `var` to read binary values.",
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
 			"namespace": {
 				Type:        schema.TypeString,
 				Description: "The namespace to lookup the keys.",
@@ -92,6 +102,7 @@ func dataSourceConsulKeysRead(d *schema.ResourceData, meta interface{}) error {
 	keyClient := newKeyClient(d, meta)
 
 	vars := make(map[string]string)
+	varsBase64 := make(map[string]string)
 
 	keys := d.Get("key").(*schema.Set).List()
 	for _, raw := range keys {
@@ -117,11 +128,15 @@ func dataSourceConsulKeysRead(d *schema.ResourceData, meta interface{}) error {
 		}
 
 		vars[key] = value
+		varsBase64[key] = base64.StdEncoding.EncodeToString([]byte(value))
 	}
 
 	if err := d.Set("var", vars); err != nil {
 		return err
 	}
+	if err := d.Set("var_base64", varsBase64); err != nil {
+		return err
+	}
 
 	// Store the datacenter on this resource, which can be helpful for reference
 	// in case it was read from the provider
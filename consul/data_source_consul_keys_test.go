@@ -4,10 +4,13 @@
 package consul
 
 import (
+	"encoding/base64"
+	"reflect"
 	"regexp"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
 func TestAccDataConsulKeys_basic(t *testing.T) {
@@ -232,3 +235,36 @@ data "consul_keys" "dc2" {
 }
 `
 )
+
+func TestDataConsulKeys_varBase64(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	// Not a valid UTF-8 string
+	binary := []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0xff}
+	kv.set("test/binary", binary, 0)
+
+	d := schema.TestResourceDataRaw(t, dataSourceConsulKeys().Schema, map[string]interface{}{
+		"key": []interface{}{
+			map[string]interface{}{
+				"name": "binary",
+				"path": "test/binary",
+			},
+			map[string]interface{}{
+				"name":    "missing",
+				"path":    "test/missing",
+				"default": "foo",
+			},
+		},
+	})
+	if err := dataSourceConsulKeysRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"binary":  base64.StdEncoding.EncodeToString(binary),
+		"missing": "Zm9v",
+	}
+	if got := d.Get("var_base64"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"fmt"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func dataSourceConsulSessions() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceConsulSessionsRead,
+
+		Description: "The `consul_sessions` data source returns the [sessions](https://developer.hashicorp.com/consul/docs/dynamic-app-config/sessions) of a datacenter, optionally filtered by node.",
+
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"node": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only return the sessions associated with this node.",
+			},
+			"datacenter": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The datacenter to use. This overrides the agent's default datacenter and the datacenter in the provider setup.",
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The namespace to lookup the sessions.",
+			},
+			"partition": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The partition to lookup the sessions.",
+			},
+
+			// Out parameters
+			"sessions": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The list of sessions.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The ID of the session.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the session.",
+						},
+						"node": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The node the session is associated with.",
+						},
+						"checks": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Description: "The IDs of the health checks associated with the session.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"ttl": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The TTL of the session.",
+						},
+						"behavior": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "What happens to the locks held by the session when it is invalidated.",
+						},
+						"lock_delay": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "How long the locks held by the session cannot be acquired after it has been invalidated.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceConsulSessionsRead(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, _ := getClient(d, meta)
+
+	var entries []*consulapi.SessionEntry
+	var err error
+	node := d.Get("node").(string)
+	if node != "" {
+		entries, _, err = client.Session().Node(node, qOpts)
+	} else {
+		entries, _, err = client.Session().List(qOpts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	sessions := make([]interface{}, len(entries))
+	for i, session := range entries {
+		sessions[i] = map[string]interface{}{
+			"id":         session.ID,
+			"name":       session.Name,
+			"node":       session.Node,
+			"checks":     sessionChecks(session),
+			"ttl":        session.TTL,
+			"behavior":   session.Behavior,
+			"lock_delay": session.LockDelay.String(),
+		}
+	}
+
+	d.SetId(fmt.Sprintf("sessions-%s-%s", qOpts.Datacenter, node))
+
+	sw := newStateWriter(d)
+	sw.set("datacenter", qOpts.Datacenter)
+	sw.set("sessions", sessions)
+
+	return sw.error()
+}
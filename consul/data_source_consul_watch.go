@@ -0,0 +1,231 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+// watchQuery performs a single blocking query and returns its result.
+type watchQuery func(opts *consulapi.QueryOptions) (interface{}, *consulapi.QueryMeta, error)
+
+func dataSourceConsulWatch() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceConsulWatchRead,
+
+		Description: "The `consul_watch` data source waits, using [blocking queries](https://developer.hashicorp.com/consul/api-docs/features/blocking), until a key, a prefix of the KV store, a service or a node changes after a given index, or until a timeout elapses. It can be used to wait for a configuration rollout before applying the resources that depend on it. Since data sources are read during the plan, the plan itself blocks while waiting.",
+
+		Schema: map[string]*schema.Schema{
+			// Filters
+			"type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				Description:  "The type of the object to watch, either `key`, `keyprefix`, `service` or `node`.",
+				ValidateFunc: validation.StringInSlice([]string{"key", "keyprefix", "service", "node"}, false),
+			},
+			"path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The path of the key, or the prefix of the keys, to watch. Required when `type` is `key` or `keyprefix`.",
+			},
+			"service": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The name of the service to watch. Required when `type` is `service`.",
+			},
+			"passing_only": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether only the instances of the service whose health checks are passing should be returned.",
+			},
+			"node": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The name of the node to watch. Required when `type` is `node`.",
+			},
+			"wait_index": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				Description:  "The index to wait past, usually the `index` returned by a previous run. With the default of `0` the current state is returned immediately.",
+				ValidateFunc: makeValidationFunc("wait_index", []interface{}{validateIntMin(0)}),
+			},
+			"timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "5m",
+				Description:  "How long to wait for a change.",
+				ValidateFunc: makeValidationFunc("timeout", []interface{}{validateDurationMin("1s")}),
+			},
+			"fail_on_timeout": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether to fail when no change happened before the timeout. Otherwise the current state is returned and `changed` is `false`.",
+			},
+			"datacenter": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The datacenter to use. This overrides the agent's default datacenter and the datacenter in the provider setup.",
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The namespace to lookup the object.",
+			},
+			"partition": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The partition to lookup the object.",
+			},
+
+			// Out parameters
+			"index": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The index of the object after the wait, to give as `wait_index` to the next run.",
+			},
+			"changed": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the index changed before the timeout.",
+			},
+			"payload": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The JSON-encoded response of the Consul API for the watched object, `null` when it does not exist.",
+			},
+			"value": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The value of the key when `type` is `key`.",
+			},
+		},
+	}
+}
+
+func dataSourceConsulWatchRead(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, _ := getClient(d, meta)
+
+	watchType := d.Get("type").(string)
+	var target string
+	var query watchQuery
+	switch watchType {
+	case "key", "keyprefix":
+		target = d.Get("path").(string)
+		if watchType == "key" {
+			query = func(opts *consulapi.QueryOptions) (interface{}, *consulapi.QueryMeta, error) {
+				return client.KV().Get(target, opts)
+			}
+		} else {
+			query = func(opts *consulapi.QueryOptions) (interface{}, *consulapi.QueryMeta, error) {
+				return client.KV().List(target, opts)
+			}
+		}
+	case "service":
+		target = d.Get("service").(string)
+		passingOnly := d.Get("passing_only").(bool)
+		query = func(opts *consulapi.QueryOptions) (interface{}, *consulapi.QueryMeta, error) {
+			return client.Health().Service(target, "", passingOnly, opts)
+		}
+	case "node":
+		target = d.Get("node").(string)
+		query = func(opts *consulapi.QueryOptions) (interface{}, *consulapi.QueryMeta, error) {
+			return client.Catalog().Node(target, opts)
+		}
+	default:
+		return fmt.Errorf("unsupported watch type %q", watchType)
+	}
+
+	if target == "" {
+		attr := map[string]string{
+			"key":       "path",
+			"keyprefix": "path",
+			"service":   "service",
+			"node":      "node",
+		}[watchType]
+		return fmt.Errorf("%q must be set to watch a %s", attr, watchType)
+	}
+
+	timeout, err := time.ParseDuration(d.Get("timeout").(string))
+	if err != nil {
+		return fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	waitIndex := uint64(d.Get("wait_index").(int))
+
+	result, index, changed, err := blockingWatch(query, qOpts, waitIndex, timeout)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s %q: %v", watchType, target, err)
+	}
+	if !changed && d.Get("fail_on_timeout").(bool) {
+		return fmt.Errorf("timeout while waiting for %s %q to change after index %d", watchType, target, waitIndex)
+	}
+
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode the payload of %s %q: %v", watchType, target, err)
+	}
+
+	var value string
+	if pair, ok := result.(*consulapi.KVPair); ok && pair != nil {
+		value = string(pair.Value)
+	}
+
+	d.SetId(fmt.Sprintf("%s-%s-%s", qOpts.Datacenter, watchType, target))
+
+	sw := newStateWriter(d)
+	sw.set("datacenter", qOpts.Datacenter)
+	sw.set("index", int(index))
+	sw.set("changed", changed)
+	sw.set("payload", string(payload))
+	sw.set("value", value)
+
+	return sw.error()
+}
+
+// minWatchWaitTime is the shortest wait time sent to Consul. The watch stops
+// once less time remains before the deadline since a wait time rounded down
+// to zero would make Consul wait for its default of 5 minutes.
+const minWatchWaitTime = 100 * time.Millisecond
+
+// blockingWatch runs query until the index it returns differs from waitIndex
+// or the timeout elapses. Consul may return before the wait time is over
+// without any change so the query is retried until the deadline.
+func blockingWatch(query watchQuery, qOpts *consulapi.QueryOptions, waitIndex uint64, timeout time.Duration) (interface{}, uint64, bool, error) {
+	deadline := time.Now().Add(timeout)
+
+	opts := *qOpts
+	opts.WaitIndex = waitIndex
+	for {
+		opts.WaitTime = time.Until(deadline)
+		if opts.WaitTime < minWatchWaitTime {
+			opts.WaitTime = minWatchWaitTime
+		}
+
+		result, meta, err := query(&opts)
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		// The index may also go backward, for example when a snapshot is
+		// restored, this is a change too
+		if waitIndex == 0 || meta.LastIndex != waitIndex {
+			return result, meta.LastIndex, true, nil
+		}
+		if time.Until(deadline) <= minWatchWaitTime {
+			return result, meta.LastIndex, false, nil
+		}
+
+		log.Printf("[DEBUG] Index %d did not change, waiting again", waitIndex)
+	}
+}
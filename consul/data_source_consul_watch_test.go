@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func TestBlockingWatch(t *testing.T) {
+	// Consul may return before the wait time is over without any change
+	indexes := []uint64{10, 10, 12}
+	calls := 0
+	query := func(opts *consulapi.QueryOptions) (interface{}, *consulapi.QueryMeta, error) {
+		if opts.WaitIndex != 10 {
+			t.Fatalf("unexpected wait index %d", opts.WaitIndex)
+		}
+		if opts.WaitTime <= 0 || opts.WaitTime > time.Minute {
+			t.Fatalf("unexpected wait time %s", opts.WaitTime)
+		}
+		index := indexes[calls]
+		calls++
+		return index, &consulapi.QueryMeta{LastIndex: index}, nil
+	}
+
+	result, index, changed, err := blockingWatch(query, &consulapi.QueryOptions{}, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !changed || index != 12 || result != uint64(12) {
+		t.Fatalf("unexpected result %v, %d, %t", result, index, changed)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestBlockingWatch_timeout(t *testing.T) {
+	query := func(opts *consulapi.QueryOptions) (interface{}, *consulapi.QueryMeta, error) {
+		time.Sleep(opts.WaitTime)
+		return "same", &consulapi.QueryMeta{LastIndex: 10}, nil
+	}
+
+	result, index, changed, err := blockingWatch(query, &consulapi.QueryOptions{}, 10, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if changed || index != 10 || result != "same" {
+		t.Fatalf("unexpected result %v, %d, %t", result, index, changed)
+	}
+}
+
+func TestBlockingWatch_minWaitTime(t *testing.T) {
+	// Consul returns early without any change, the watch must stop before
+	// sending a wait time that it would replace by its default
+	calls := 0
+	query := func(opts *consulapi.QueryOptions) (interface{}, *consulapi.QueryMeta, error) {
+		if opts.WaitTime < minWatchWaitTime {
+			t.Fatalf("unexpected wait time %s", opts.WaitTime)
+		}
+		calls++
+		time.Sleep(10 * time.Millisecond)
+		return "same", &consulapi.QueryMeta{LastIndex: 10}, nil
+	}
+
+	start := time.Now()
+	_, _, changed, err := blockingWatch(query, &consulapi.QueryOptions{}, 10, 3*minWatchWaitTime)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if changed {
+		t.Fatal("expected no change")
+	}
+	if elapsed := time.Since(start); elapsed > 3*minWatchWaitTime {
+		t.Fatalf("the watch should stop before the deadline, took %s", elapsed)
+	}
+	if calls < 2 {
+		t.Fatalf("expected the query to be retried, got %d calls", calls)
+	}
+}
+
+func TestDataConsulWatch(t *testing.T) {
+	kv, config := newFakeKV(t)
+	kv.set("app/version", []byte("1.2.3"), 0)
+
+	d := schema.TestResourceDataRaw(t, dataSourceConsulWatch().Schema, map[string]interface{}{
+		"type": "key",
+		"path": "app/version",
+	})
+	if err := dataSourceConsulWatchRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := d.Get("index"); got != 1 {
+		t.Fatalf("expected index 1, got %v", got)
+	}
+	if got := d.Get("changed"); got != true {
+		t.Fatalf("expected changed to be true, got %v", got)
+	}
+	if got := d.Get("value"); got != "1.2.3" {
+		t.Fatalf("unexpected value %v", got)
+	}
+	expected := `{"Key":"app/version","CreateIndex":0,"ModifyIndex":1,"LockIndex":0,"Flags":0,"Value":"MS4yLjM=","Session":""}`
+	if got := d.Get("payload"); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	// The fake server does not block so the timeout is reached right away
+	d = schema.TestResourceDataRaw(t, dataSourceConsulWatch().Schema, map[string]interface{}{
+		"type":            "keyprefix",
+		"path":            "app/",
+		"wait_index":      1,
+		"timeout":         "1ms",
+		"fail_on_timeout": true,
+	})
+	err := dataSourceConsulWatchRead(d, config)
+	if err == nil || !regexp.MustCompile(`timeout while waiting for keyprefix "app/" to change after index 1`).MatchString(err.Error()) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceConsulWatch().Schema, map[string]interface{}{
+		"type": "service",
+	})
+	err = dataSourceConsulWatchRead(d, config)
+	if err == nil || err.Error() != `"service" must be set to watch a service` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAccDataConsulWatch_basic(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers: providers,
+		PreCheck: func() {
+			_, err := client.KV().Put(&consulapi.KVPair{Key: "test/watch", Value: []byte("v1")}, nil)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataConsulWatchConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.consul_watch.key", "changed", "true"),
+					resource.TestCheckResourceAttr("data.consul_watch.key", "value", "v1"),
+					resource.TestCheckResourceAttrSet("data.consul_watch.key", "index"),
+					resource.TestCheckResourceAttr("data.consul_watch.service", "changed", "true"),
+					resource.TestCheckResourceAttrSet("data.consul_watch.service", "payload"),
+					resource.TestCheckResourceAttr("data.consul_watch.timeout", "changed", "false"),
+				),
+			},
+		},
+	})
+}
+
+const testAccDataConsulWatchConfig = `
+data "consul_watch" "key" {
+  type = "key"
+  path = "test/watch"
+}
+
+data "consul_watch" "service" {
+  type    = "service"
+  service = "consul"
+}
+
+data "consul_watch" "timeout" {
+  type       = "key"
+  path       = "test/watch"
+  wait_index = data.consul_watch.key.index
+  timeout    = "1s"
+}
+`
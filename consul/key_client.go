@@ -4,10 +4,12 @@
 package consul
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
 	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
@@ -15,34 +17,102 @@ import (
 // specialized for Terraform's manipulations of the key/value store.
 type keyClient struct {
 	client *consulapi.KV
+	txn    *consulapi.Txn
 	qOpts  *consulapi.QueryOptions
 	wOpts  *consulapi.WriteOptions
 }
 
+// keyTxnMaxOps is the maximum number of operations Consul accepts in a
+// single transaction.
+const keyTxnMaxOps = 64
+
+// keyBatch collects writes to the key/value store so that they can be applied
+// together with keyClient.Apply.
+//
+// When indexes is set, the writes to the keys it holds use check-and-set
+// operations and only succeed if the ModifyIndex of the key is still the one
+// recorded, an index of 0 meaning that the key must not exist.
+type keyBatch struct {
+	ops     consulapi.KVTxnOps
+	indexes map[string]uint64
+}
+
+func (b *keyBatch) Put(path, value string, flags int) {
+	op := &consulapi.KVTxnOp{
+		Verb:  consulapi.KVSet,
+		Key:   path,
+		Value: []byte(value),
+		Flags: uint64(flags),
+	}
+	if index, ok := b.indexes[path]; ok {
+		op.Verb = consulapi.KVCAS
+		op.Index = index
+	}
+	b.ops = append(b.ops, op)
+}
+
+func (b *keyBatch) Delete(path string) {
+	op := &consulapi.KVTxnOp{
+		Verb: consulapi.KVDelete,
+		Key:  path,
+	}
+	if index, ok := b.indexes[path]; ok {
+		op.Verb = consulapi.KVDeleteCAS
+		op.Index = index
+	}
+	b.ops = append(b.ops, op)
+}
+
+// keyIndexes converts the modify_index attribute of the KV resources to the
+// indexes of a keyBatch, prepending pathPrefix to the names of the keys.
+func keyIndexes(raw interface{}, pathPrefix string) map[string]uint64 {
+	indexes := map[string]uint64{}
+	m, _ := raw.(map[string]interface{})
+	for name, index := range m {
+		indexes[pathPrefix+name] = uint64(index.(int))
+	}
+	return indexes
+}
+
+// keyCASEnabled reports whether the writes of a KV resource must use
+// check-and-set operations. They are not used when cas has just been enabled
+// on an existing resource since the indexes of its keys are not known yet.
+func keyCASEnabled(d *schema.ResourceData) bool {
+	o, n := d.GetChange("cas")
+	return n.(bool) && (o.(bool) || d.IsNewResource())
+}
+
 func newKeyClient(d *schema.ResourceData, meta interface{}) *keyClient {
 	client, qOpts, wOpts := getClient(d, meta)
 
 	return &keyClient{
 		client: client.KV(),
+		txn:    client.Txn(),
 		qOpts:  qOpts,
 		wOpts:  wOpts,
 	}
 }
 
 func (c *keyClient) Get(path string) (bool, string, int, error) {
+	pair, err := c.GetPair(path)
+	if err != nil || pair == nil {
+		return false, "", 0, err
+	}
+
+	return true, string(pair.Value), int(pair.Flags), nil
+}
+
+// GetPair returns the key stored at path, or nil when it does not exist.
+func (c *keyClient) GetPair(path string) (*consulapi.KVPair, error) {
 	log.Printf(
 		"[DEBUG] Reading key '%s' in %s",
 		path, c.qOpts.Datacenter,
 	)
 	pair, _, err := c.client.Get(path, c.qOpts)
 	if err != nil {
-		return false, "", 0, fmt.Errorf("failed to read Consul key '%s': %s", path, err)
+		return nil, fmt.Errorf("failed to read Consul key '%s': %s", path, err)
 	}
-	if pair == nil {
-		return false, "", 0, nil
-	}
-
-	return true, string(pair.Value), int(pair.Flags), nil
+	return pair, nil
 }
 
 func (c *keyClient) GetUnderPrefix(pathPrefix string) (consulapi.KVPairs, error) {
@@ -59,10 +129,27 @@ func (c *keyClient) GetUnderPrefix(pathPrefix string) (consulapi.KVPairs, error)
 	return pairs, nil
 }
 
+// GetKeysUnderPrefix returns the paths of the keys under pathPrefix without
+// their values.
+func (c *keyClient) GetKeysUnderPrefix(pathPrefix string) ([]string, error) {
+	log.Printf(
+		"[DEBUG] Listing key paths under '%s' in %s",
+		pathPrefix, c.qOpts.Datacenter,
+	)
+	keys, _, err := c.client.Keys(pathPrefix, "", c.qOpts)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to list Consul keys under prefix '%s': %s", pathPrefix, err,
+		)
+	}
+	return keys, nil
+}
+
 func (c *keyClient) Put(path, value string, flags int) error {
+	// The value is not logged since it may hold a secret
 	log.Printf(
-		"[DEBUG] Setting key '%s' to '%v' in %s",
-		path, value, c.wOpts.Datacenter,
+		"[DEBUG] Setting key '%s' (%d bytes) in %s",
+		path, len(value), c.wOpts.Datacenter,
 	)
 	pair := consulapi.KVPair{Key: path, Value: []byte(value), Flags: uint64(flags)}
 	if _, err := c.client.Put(&pair, c.wOpts); err != nil {
@@ -82,6 +169,67 @@ func (c *keyClient) Delete(path string) error {
 	return nil
 }
 
+// PutCAS writes the key only if its ModifyIndex is still index.
+func (c *keyClient) PutCAS(path, value string, flags int, index uint64) error {
+	log.Printf(
+		"[DEBUG] Setting key '%s' (%d bytes) at index %d in %s",
+		path, len(value), index, c.wOpts.Datacenter,
+	)
+	pair := consulapi.KVPair{Key: path, Value: []byte(value), Flags: uint64(flags), ModifyIndex: index}
+	ok, _, err := c.client.CAS(&pair, c.wOpts)
+	if err != nil {
+		return fmt.Errorf("failed to write Consul key '%s': %s", path, err)
+	}
+	if !ok {
+		return c.conflictError(path, index)
+	}
+	return nil
+}
+
+// DeleteCAS deletes the key only if its ModifyIndex is still index.
+func (c *keyClient) DeleteCAS(path string, index uint64) error {
+	log.Printf(
+		"[DEBUG] Deleting key '%s' at index %d in %s",
+		path, index, c.wOpts.Datacenter,
+	)
+	pair := consulapi.KVPair{Key: path, ModifyIndex: index}
+	ok, _, err := c.client.DeleteCAS(&pair, c.wOpts)
+	if err != nil {
+		return fmt.Errorf("failed to delete Consul key '%s': %s", path, err)
+	}
+	if !ok {
+		return c.conflictError(path, index)
+	}
+	return nil
+}
+
+// conflictError reports that a check-and-set operation on path failed
+// because the key has been modified since it was last read.
+func (c *keyClient) conflictError(path string, expected uint64) error {
+	current, err := c.modifyIndex(path)
+	if err != nil {
+		return fmt.Errorf("conflict on Consul key '%s': it has been modified since ModifyIndex %d", path, expected)
+	}
+	return keyConflictError(path, expected, current)
+}
+
+func keyConflictError(path string, expected, current uint64) error {
+	return fmt.Errorf(
+		"conflict on Consul key '%s': expected ModifyIndex %d but found %d, it has been modified outside of Terraform",
+		path, expected, current,
+	)
+}
+
+// modifyIndex returns the current ModifyIndex of the key, 0 if it does not
+// exist.
+func (c *keyClient) modifyIndex(path string) (uint64, error) {
+	pair, err := c.GetPair(path)
+	if err != nil || pair == nil {
+		return 0, err
+	}
+	return pair.ModifyIndex, nil
+}
+
 func (c *keyClient) DeleteUnderPrefix(pathPrefix string) error {
 	log.Printf(
 		"[DEBUG] Deleting all keys under prefix '%s' in %s",
@@ -92,3 +240,83 @@ func (c *keyClient) DeleteUnderPrefix(pathPrefix string) error {
 	}
 	return nil
 }
+
+// Apply writes all the operations of the batch. When atomic is set they are
+// sent using transactions so that readers never see a partial update; since
+// Consul limits the size of a transaction, batches larger than keyTxnMaxOps
+// operations are split and only each chunk is atomic. Otherwise each key is
+// written with its own request.
+func (c *keyClient) Apply(b *keyBatch, atomic bool) error {
+	if !atomic {
+		for _, op := range b.ops {
+			var err error
+			switch op.Verb {
+			case consulapi.KVSet:
+				err = c.Put(op.Key, string(op.Value), int(op.Flags))
+			case consulapi.KVDelete:
+				err = c.Delete(op.Key)
+			case consulapi.KVCAS:
+				err = c.PutCAS(op.Key, string(op.Value), int(op.Flags), op.Index)
+			case consulapi.KVDeleteCAS:
+				err = c.DeleteCAS(op.Key, op.Index)
+			default:
+				err = fmt.Errorf("unsupported operation %q on key '%s'", op.Verb, op.Key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for start := 0; start < len(b.ops); start += keyTxnMaxOps {
+		end := start + keyTxnMaxOps
+		if end > len(b.ops) {
+			end = len(b.ops)
+		}
+		if err := c.applyTxn(b.ops[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *keyClient) applyTxn(kvOps consulapi.KVTxnOps) error {
+	log.Printf(
+		"[DEBUG] Applying a transaction of %d operations in %s",
+		len(kvOps), c.wOpts.Datacenter,
+	)
+
+	ops := make(consulapi.TxnOps, 0, len(kvOps))
+	for _, op := range kvOps {
+		ops = append(ops, &consulapi.TxnOp{KV: op})
+	}
+
+	ok, resp, _, err := c.txn.Txn(ops, c.qOpts)
+	if err != nil {
+		return fmt.Errorf("failed to apply Consul transaction: %s", err)
+	}
+	if ok {
+		return nil
+	}
+
+	var errs error
+	for _, e := range resp.Errors {
+		if e.OpIndex < 0 || e.OpIndex >= len(kvOps) {
+			errs = multierror.Append(errs, errors.New(e.What))
+			continue
+		}
+		op := kvOps[e.OpIndex]
+		if op.Verb == consulapi.KVCAS || op.Verb == consulapi.KVDeleteCAS {
+			if current, err := c.modifyIndex(op.Key); err == nil && current != op.Index {
+				errs = multierror.Append(errs, keyConflictError(op.Key, op.Index, current))
+				continue
+			}
+		}
+		errs = multierror.Append(errs, fmt.Errorf("failed to %s Consul key '%s': %s", op.Verb, op.Key, e.What))
+	}
+	if errs == nil {
+		errs = errors.New("failed to apply Consul transaction: transaction rolled back")
+	}
+	return errs
+}
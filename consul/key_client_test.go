@@ -0,0 +1,311 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// txnHandler records the transactions it receives and fails the operations
+// on the keys listed in failures. The keys listed in indexes exist with the
+// given ModifyIndex, the check-and-set operations on them fail when using
+// another index.
+type txnHandler struct {
+	mu       sync.Mutex
+	txns     [][]*consulapi.TxnOp
+	requests []string
+	failures map[string]string
+	indexes  map[string]uint64
+}
+
+func (h *txnHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.requests = append(h.requests, req.Method+" "+req.URL.Path)
+	if req.URL.Path != "/v1/txn" {
+		key := strings.TrimPrefix(req.URL.Path, "/v1/kv/")
+		index, exists := h.indexes[key]
+		switch {
+		case req.Method == http.MethodGet && !exists:
+			w.WriteHeader(http.StatusNotFound)
+		case req.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(consulapi.KVPairs{{Key: key, ModifyIndex: index}})
+		case req.URL.Query().Has("cas") && req.URL.Query().Get("cas") != strconv.FormatUint(index, 10):
+			_, _ = w.Write([]byte("false"))
+		default:
+			_, _ = w.Write([]byte("true"))
+		}
+		return
+	}
+
+	var ops []*consulapi.TxnOp
+	if err := json.NewDecoder(req.Body).Decode(&ops); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.txns = append(h.txns, ops)
+
+	resp := consulapi.TxnResponse{}
+	for i, op := range ops {
+		if what, ok := h.failures[op.KV.Key]; ok {
+			resp.Errors = append(resp.Errors, &consulapi.TxnError{OpIndex: i, What: what})
+		}
+	}
+	if len(resp.Errors) > 0 {
+		w.WriteHeader(http.StatusConflict)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func testKeyClient(t *testing.T, h http.Handler) *keyClient {
+	t.Helper()
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	client, err := consulapi.NewClient(&consulapi.Config{
+		Address: strings.TrimPrefix(server.URL, "http://"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &keyClient{
+		client: client.KV(),
+		txn:    client.Txn(),
+		qOpts:  &consulapi.QueryOptions{Datacenter: "dc1", Namespace: "ns"},
+		wOpts:  &consulapi.WriteOptions{Datacenter: "dc1", Namespace: "ns"},
+	}
+}
+
+func TestKeyClient_Apply(t *testing.T) {
+	h := &txnHandler{}
+	c := testKeyClient(t, h)
+
+	batch := &keyBatch{}
+	for i := 0; i < 130; i++ {
+		batch.Put(fmt.Sprintf("foo/%d", i), "bar", 42)
+	}
+	batch.Delete("foo/old")
+
+	if err := c.Apply(batch, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(h.txns) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(h.txns))
+	}
+	for i, expected := range []int{64, 64, 3} {
+		if len(h.txns[i]) != expected {
+			t.Errorf("expected %d operations in transaction %d, got %d", expected, i, len(h.txns[i]))
+		}
+	}
+
+	first := h.txns[0][0].KV
+	if first.Verb != consulapi.KVSet || first.Key != "foo/0" || string(first.Value) != "bar" || first.Flags != 42 {
+		t.Errorf("unexpected operation %#v", first)
+	}
+	last := h.txns[2][2].KV
+	if last.Verb != consulapi.KVDelete || last.Key != "foo/old" {
+		t.Errorf("unexpected operation %#v", last)
+	}
+}
+
+func TestKeyClient_Apply_error(t *testing.T) {
+	h := &txnHandler{failures: map[string]string{"foo/b": "permission denied"}}
+	c := testKeyClient(t, h)
+
+	batch := &keyBatch{}
+	batch.Put("foo/a", "a", 0)
+	batch.Delete("foo/b")
+
+	err := c.Apply(batch, true)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "failed to delete Consul key 'foo/b': permission denied") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestKeyClient_Apply_withoutTransactions(t *testing.T) {
+	h := &txnHandler{}
+	c := testKeyClient(t, h)
+
+	batch := &keyBatch{}
+	batch.Put("foo/a", "a", 0)
+	batch.Delete("foo/b")
+
+	if err := c.Apply(batch, false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(h.txns) != 0 {
+		t.Fatalf("expected no transaction, got %d", len(h.txns))
+	}
+	expected := []string{"PUT /v1/kv/foo/a", "DELETE /v1/kv/foo/b"}
+	if strings.Join(h.requests, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected requests %v, got %v", expected, h.requests)
+	}
+}
+
+func TestKeyClient_Apply_cas(t *testing.T) {
+	for _, atomic := range []bool{true, false} {
+		t.Run(fmt.Sprintf("atomic=%t", atomic), func(t *testing.T) {
+			h := &txnHandler{
+				indexes:  map[string]uint64{"foo/a": 10, "foo/b": 12},
+				failures: map[string]string{"foo/b": `failed to set key "foo/b", index is stale`},
+			}
+			c := testKeyClient(t, h)
+
+			batch := &keyBatch{indexes: map[string]uint64{"foo/a": 10, "foo/b": 11}}
+			batch.Put("foo/a", "a", 0)
+			batch.Delete("foo/b")
+			batch.Put("foo/c", "c", 0)
+
+			if batch.ops[0].Verb != consulapi.KVCAS || batch.ops[0].Index != 10 {
+				t.Errorf("unexpected operation %#v", batch.ops[0])
+			}
+			if batch.ops[1].Verb != consulapi.KVDeleteCAS || batch.ops[1].Index != 11 {
+				t.Errorf("unexpected operation %#v", batch.ops[1])
+			}
+			if batch.ops[2].Verb != consulapi.KVSet {
+				t.Errorf("unexpected operation %#v", batch.ops[2])
+			}
+
+			err := c.Apply(batch, atomic)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			expected := "conflict on Consul key 'foo/b': expected ModifyIndex 11 but found 12"
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf("expected %q, got %q", expected, err)
+			}
+		})
+	}
+}
+
+func TestKeyClient_PutCAS_missingKey(t *testing.T) {
+	h := &txnHandler{indexes: map[string]uint64{}}
+	c := testKeyClient(t, h)
+
+	if err := c.PutCAS("foo/a", "a", 0, 0); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	h.indexes["foo/a"] = 5
+	err := c.PutCAS("foo/a", "a", 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "expected ModifyIndex 0 but found 5") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// fakeKV is an in-memory implementation of the KV and transaction endpoints
+// of Consul.
+type fakeKV struct {
+	mu    sync.Mutex
+	pairs map[string]*consulapi.KVPair
+	index uint64
+}
+
+func newFakeKV(t *testing.T) (*fakeKV, *Config) {
+	t.Helper()
+
+	kv := &fakeKV{pairs: map[string]*consulapi.KVPair{}}
+	server := httptest.NewServer(kv)
+	t.Cleanup(server.Close)
+
+	config := &Config{
+		Address:    strings.TrimPrefix(server.URL, "http://"),
+		Datacenter: "dc1",
+	}
+	client, err := config.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config.client = client
+	return kv, config
+}
+
+func (kv *fakeKV) set(key string, value []byte, flags uint64) {
+	kv.index++
+	kv.pairs[key] = &consulapi.KVPair{Key: key, Value: value, Flags: flags, ModifyIndex: kv.index}
+}
+
+func (kv *fakeKV) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if req.URL.Path == "/v1/txn" {
+		var ops []*consulapi.TxnOp
+		if err := json.NewDecoder(req.Body).Decode(&ops); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, op := range ops {
+			switch op.KV.Verb {
+			case consulapi.KVSet, consulapi.KVCAS:
+				kv.set(op.KV.Key, op.KV.Value, op.KV.Flags)
+			case consulapi.KVDelete, consulapi.KVDeleteCAS:
+				delete(kv.pairs, op.KV.Key)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(consulapi.TxnResponse{})
+		return
+	}
+
+	key := strings.TrimPrefix(req.URL.Path, "/v1/kv/")
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(kv.index, 10))
+		if req.URL.Query().Has("keys") {
+			keys := []string{}
+			for k := range kv.pairs {
+				if strings.HasPrefix(k, key) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			_ = json.NewEncoder(w).Encode(keys)
+			return
+		}
+
+		pairs := consulapi.KVPairs{}
+		for k, pair := range kv.pairs {
+			if k == key || (req.URL.Query().Has("recurse") && strings.HasPrefix(k, key)) {
+				pairs = append(pairs, pair)
+			}
+		}
+		if len(pairs) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+		_ = json.NewEncoder(w).Encode(pairs)
+	case http.MethodPut:
+		value, _ := io.ReadAll(req.Body)
+		flags, _ := strconv.ParseUint(req.URL.Query().Get("flags"), 10, 64)
+		kv.set(key, value, flags)
+		_, _ = w.Write([]byte("true"))
+	case http.MethodDelete:
+		for k := range kv.pairs {
+			if k == key || (req.URL.Query().Has("recurse") && strings.HasPrefix(k, key)) {
+				delete(kv.pairs, k)
+			}
+		}
+		_, _ = w.Write([]byte("true"))
+	}
+}
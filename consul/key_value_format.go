@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// keyValueFormats are the formats supported by the value_format argument of
+// the KV resources. When one is set, the values that only differ in their
+// formatting, like whitespaces or the order of the keys of an object, are
+// considered equal.
+var keyValueFormats = []string{"json", "yaml"}
+
+func decodeKeyValue(format, value string) (interface{}, error) {
+	var v interface{}
+	switch format {
+	case "json":
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		if err := yaml.Unmarshal([]byte(value), &v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return v, nil
+}
+
+// validateKeyValue returns an error if value cannot be decoded using the
+// given format. Any value is valid when no format is set.
+func validateKeyValue(format, path, value string) error {
+	if format == "" {
+		return nil
+	}
+	if _, err := decodeKeyValue(format, value); err != nil {
+		return fmt.Errorf("the value of key %q is not valid %s: %v", path, format, err)
+	}
+	return nil
+}
+
+// validateSubkeys checks at plan time that the values of the subkeys
+// attribute of consul_key_prefix are valid documents in subkeys_format. The
+// values that are not known yet are checked during the apply.
+func validateSubkeys(d *schema.ResourceDiff) error {
+	if !d.NewValueKnown("subkeys_format") {
+		return nil
+	}
+
+	format := d.Get("subkeys_format").(string)
+	for k, v := range d.Get("subkeys").(map[string]interface{}) {
+		if !d.NewValueKnown("subkeys." + k) {
+			continue
+		}
+		if err := validateKeyValue(format, k, v.(string)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyValuesEqual reports whether a and b hold the same document in the given
+// format. Values that cannot be decoded are only equal when they are
+// identical.
+func keyValuesEqual(format, a, b string) bool {
+	if a == b {
+		return true
+	}
+	if format == "" {
+		return false
+	}
+
+	va, err := decodeKeyValue(format, a)
+	if err != nil {
+		return false
+	}
+	vb, err := decodeKeyValue(format, b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// normalizeKeyValue returns the value to store in the state for a key read
+// from Consul: the one previously written by Terraform if it holds the same
+// document so that no diff is reported, the value read otherwise.
+func normalizeKeyValue(format, current, read string) string {
+	if keyValuesEqual(format, current, read) {
+		return current
+	}
+	return read
+}
+
+// diffKeyValue suppresses the diff of the values of the subkeys attribute of
+// consul_key_prefix when they hold the same document.
+func diffKeyValue(k, old, new string, d *schema.ResourceData) bool {
+	return keyValuesEqual(d.Get("subkeys_format").(string), old, new)
+}
@@ -0,0 +1,260 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestKeyValuesEqual(t *testing.T) {
+	testCases := map[string]struct {
+		format string
+		a, b   string
+		equal  bool
+	}{
+		"no-format-identical": {
+			a:     `{"a":1}`,
+			b:     `{"a":1}`,
+			equal: true,
+		},
+		"no-format-whitespace": {
+			a: `{"a":1}`,
+			b: `{ "a": 1 }`,
+		},
+		"json-whitespace-and-order": {
+			format: "json",
+			a:      `{"a":1,"b":[true,null]}`,
+			b:      "{\n  \"b\": [true, null],\n  \"a\": 1.0\n}",
+			equal:  true,
+		},
+		"json-different": {
+			format: "json",
+			a:      `{"a":1}`,
+			b:      `{"a":2}`,
+		},
+		"json-invalid": {
+			format: "json",
+			a:      `{"a":1}`,
+			b:      `{"a":1`,
+		},
+		"yaml-formatting": {
+			format: "yaml",
+			a:      "a: 1\nb:\n  - foo\n",
+			b:      "b: [foo]\na: 1 # comment\n",
+			equal:  true,
+		},
+		"yaml-json-document": {
+			format: "yaml",
+			a:      "a: 1\n",
+			b:      `{"a": 1}`,
+			equal:  true,
+		},
+		"yaml-different": {
+			format: "yaml",
+			a:      "a: 1\n",
+			b:      "a: '1'\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := keyValuesEqual(tc.format, tc.a, tc.b); got != tc.equal {
+				t.Fatalf("expected %t, got %t", tc.equal, got)
+			}
+		})
+	}
+}
+
+func TestValidateKeyValue(t *testing.T) {
+	if err := validateKeyValue("", "foo", "{"); err != nil {
+		t.Fatalf("any value should be valid without format, got %s", err)
+	}
+	if err := validateKeyValue("json", "foo", `{"a": 1}`); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := validateKeyValue("json", "foo", "{"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := validateKeyValue("yaml", "foo", "a: [1"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestKeyValueFormat_plan(t *testing.T) {
+	testCases := map[string]struct {
+		resource *schema.Resource
+		config   map[string]interface{}
+		valid    bool
+	}{
+		"keys-valid": {
+			resource: resourceConsulKeys(),
+			config: map[string]interface{}{
+				"key": []interface{}{
+					map[string]interface{}{"path": "foo", "value": `{"a": 1}`, "value_format": "json"},
+				},
+			},
+			valid: true,
+		},
+		"keys-invalid": {
+			resource: resourceConsulKeys(),
+			config: map[string]interface{}{
+				"key": []interface{}{
+					map[string]interface{}{"path": "foo", "value": "{", "value_format": "json"},
+				},
+			},
+		},
+		"keys-unknown": {
+			resource: resourceConsulKeys(),
+			config: map[string]interface{}{
+				"key": []interface{}{
+					map[string]interface{}{"path": "foo", "value": unknownVariableValue, "value_format": "json"},
+				},
+			},
+			valid: true,
+		},
+		"subkey-invalid": {
+			resource: resourceConsulKeyPrefix(),
+			config: map[string]interface{}{
+				"path_prefix": "prefix/",
+				"subkey": []interface{}{
+					map[string]interface{}{"path": "foo", "value": "a: [1", "value_format": "yaml"},
+				},
+			},
+		},
+		"subkey-base64-invalid": {
+			resource: resourceConsulKeyPrefix(),
+			config: map[string]interface{}{
+				"path_prefix": "prefix/",
+				"subkey": []interface{}{
+					// "{"
+					map[string]interface{}{"path": "foo", "value_base64": "ew==", "value_format": "json"},
+				},
+			},
+		},
+		"subkeys-valid": {
+			resource: resourceConsulKeyPrefix(),
+			config: map[string]interface{}{
+				"path_prefix":    "prefix/",
+				"subkeys":        map[string]interface{}{"foo": "a: 1"},
+				"subkeys_format": "yaml",
+			},
+			valid: true,
+		},
+		"subkeys-invalid": {
+			resource: resourceConsulKeyPrefix(),
+			config: map[string]interface{}{
+				"path_prefix":    "prefix/",
+				"subkeys":        map[string]interface{}{"foo": "{"},
+				"subkeys_format": "json",
+			},
+		},
+		"subkeys-unknown": {
+			resource: resourceConsulKeyPrefix(),
+			config: map[string]interface{}{
+				"path_prefix":    "prefix/",
+				"subkeys":        map[string]interface{}{"foo": unknownVariableValue, "bar": "{"},
+				"subkeys_format": "json",
+			},
+			valid: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := tc.resource.Diff(nil, terraform.NewResourceConfigRaw(tc.config), nil)
+			if tc.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.valid && (err == nil || !strings.Contains(err.Error(), `the value of key "foo" is not valid`)) {
+				t.Fatalf("expected the value to be rejected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResourceConsulKeys_valueFormat(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	r := resourceConsulKeys()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"key": []interface{}{
+			map[string]interface{}{
+				"path":         "test/config",
+				"value":        `{"a":1,"b":"foo"}`,
+				"value_format": "json",
+			},
+		},
+	})
+	if err := resourceConsulKeysCreateUpdate(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Another writer stores the same document with another formatting
+	kv.set("test/config", []byte("{\n  \"b\": \"foo\",\n  \"a\": 1\n}"), 0)
+	if err := resourceConsulKeysRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	key := d.Get("key").(*schema.Set).List()[0].(map[string]interface{})
+	if key["value"] != `{"a":1,"b":"foo"}` {
+		t.Fatalf("expected the value to be kept, got %q", key["value"])
+	}
+
+	// The document is changed
+	kv.set("test/config", []byte(`{"a":2,"b":"foo"}`), 0)
+	if err := resourceConsulKeysRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	key = d.Get("key").(*schema.Set).List()[0].(map[string]interface{})
+	if key["value"] != `{"a":2,"b":"foo"}` {
+		t.Fatalf("expected the drift to be detected, got %q", key["value"])
+	}
+
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"key": []interface{}{
+			map[string]interface{}{
+				"path":         "test/invalid",
+				"value":        `{"a":`,
+				"value_format": "json",
+			},
+		},
+	})
+	if err := resourceConsulKeysCreateUpdate(d, config); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestResourceConsulKeyPrefix_subkeysFormat(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	r := resourceConsulKeyPrefix()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"path_prefix":    "prefix_test/",
+		"subkeys_format": "yaml",
+		"subkeys": map[string]interface{}{
+			"config": "a: 1\nb: foo\n",
+		},
+	})
+	if err := resourceConsulKeyPrefixCreate(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	kv.set("prefix_test/config", []byte("b: foo\na: 1\n"), 0)
+	if err := resourceConsulKeyPrefixRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v := d.Get("subkeys.config"); v != "a: 1\nb: foo\n" {
+		t.Fatalf("expected the value to be kept, got %q", v)
+	}
+
+	if !diffKeyValue("subkeys.config", "a: 1\nb: foo\n", "{b: foo, a: 1}", d) {
+		t.Fatal("expected the diff to be suppressed")
+	}
+	if diffKeyValue("subkeys.config", "a: 1\n", "a: 2\n", d) {
+		t.Fatal("expected the diff not to be suppressed")
+	}
+}
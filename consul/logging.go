@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const redacted = "<redacted>"
+
+// logLevels are the values accepted by the log_level argument of the provider.
+var logLevels = []string{"trace", "debug", "info", "warn", "error"}
+
+// sensitiveHeaders are never written to the logs.
+var sensitiveHeaders = []string{
+	"X-Consul-Token",
+	"Authorization",
+}
+
+// sensitiveFieldNames are the fields of the Consul API payloads holding
+// secrets: ACL tokens, login bearer tokens, peering tokens, private keys, the
+// tokens stored in prepared queries or in the CA provider configuration and
+// the secrets of the auth methods.
+const sensitiveFieldNames = `SecretID|BearerToken|PeeringToken|PrivateKey|Token|ServiceAccountJWT|OIDCClientSecret`
+
+// sensitiveJSONFields matches the sensitiveFieldNames in a JSON body.
+var sensitiveJSONFields = regexp.MustCompile(`"(` + sensitiveFieldNames + `)"(\s*):(\s*)"(?:[^"\\]|\\.)*"`)
+
+// truncatedSensitiveJSONField matches a secret cut by the truncation of a
+// body.
+var truncatedSensitiveJSONField = regexp.MustCompile(`"(` + sensitiveFieldNames + `)"(\s*):(\s*)"(?:[^"\\]|\\.)*\\?$`)
+
+// maxLoggedBodySize is the number of bytes of the request and response bodies
+// written to the logs, the rest is truncated.
+const maxLoggedBodySize = 4096
+
+// binaryEndpoints are the paths whose bodies are not logged since they are
+// not text and can be very large.
+var binaryEndpoints = []string{
+	"/v1/snapshot",
+}
+
+func newLogger(level string) hclog.Logger {
+	if level == "" {
+		level = "debug"
+	}
+
+	return hclog.New(&hclog.LoggerOptions{
+		Name:   "consul-provider",
+		Level:  hclog.LevelFromString(level),
+		Output: os.Stderr,
+	})
+}
+
+// redactHeaders returns a copy of the headers with the secrets removed.
+func redactHeaders(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range sensitiveHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+	return h
+}
+
+// redactBody removes the secrets from a request or response body. The values
+// of the KV store are never logged since there is no way to know whether they
+// are sensitive.
+func redactBody(path string, body []byte) string {
+	if strings.HasPrefix(path, "/v1/kv/") || strings.HasPrefix(path, "/v1/txn") {
+		return redacted
+	}
+	redactedBody := sensitiveJSONFields.ReplaceAllString(string(body), `"$1"$2:$3"`+redacted+`"`)
+	return truncatedSensitiveJSONField.ReplaceAllString(redactedBody, `"$1"$2:$3"`+redacted)
+}
+
+// logBody returns the body to write in the logs: the secrets are redacted
+// and only its first maxLoggedBodySize bytes are kept.
+func logBody(path string, body []byte) string {
+	for _, prefix := range binaryEndpoints {
+		if strings.HasPrefix(path, prefix) {
+			return "<binary>"
+		}
+	}
+
+	if len(body) <= maxLoggedBodySize {
+		return redactBody(path, body)
+	}
+	return redactBody(path, body[:maxLoggedBodySize]) + "...<truncated>"
+}
+
+// readBodyPrefix reads the first bytes of body that can be logged and
+// returns a new body that still returns all the content.
+func readBodyPrefix(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	// One more byte is read to know whether the body must be truncated
+	prefix, err := io.ReadAll(io.LimitReader(body, maxLoggedBodySize+1))
+	return prefix, readCloser{io.MultiReader(bytes.NewReader(prefix), body), body}, err
+}
+
+// loggingTransport logs each request sent to Consul with its status and
+// latency. The headers and bodies are also logged at the trace level, with
+// the secrets redacted.
+type loggingTransport struct {
+	http.RoundTripper
+
+	logger hclog.Logger
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := t.logger.IsTrace()
+	if trace {
+		var body []byte
+		if req.Body != nil && req.GetBody != nil {
+			if b, err := req.GetBody(); err == nil {
+				body, _ = io.ReadAll(io.LimitReader(b, maxLoggedBodySize+1))
+				b.Close()
+			}
+		}
+		t.logger.Trace("Sending request",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"headers", redactHeaders(req.Header),
+			"body", logBody(req.URL.Path, body),
+		)
+	}
+
+	start := time.Now()
+	resp, err := t.RoundTripper.RoundTrip(req)
+	latency := time.Since(start)
+	if err != nil {
+		t.logger.Debug("Request failed",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"latency", latency,
+			"error", err,
+		)
+		return resp, err
+	}
+
+	t.logger.Debug("Request completed",
+		"method", req.Method,
+		"path", req.URL.Path,
+		"status", resp.StatusCode,
+		"latency", latency,
+	)
+
+	if trace {
+		var body []byte
+		if !isBinaryResponse(req, resp) {
+			body, resp.Body, err = readBodyPrefix(resp.Body)
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
+
+		t.logger.Trace("Received response",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"headers", redactHeaders(resp.Header),
+			"body", logBody(req.URL.Path, body),
+		)
+	}
+
+	return resp, nil
+}
+
+// isBinaryResponse returns whether the body of resp must not be read to be
+// logged.
+func isBinaryResponse(req *http.Request, resp *http.Response) bool {
+	for _, prefix := range binaryEndpoints {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return true
+		}
+	}
+	return resp.Header.Get("Content-Type") == "application/octet-stream"
+}
@@ -0,0 +1,209 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestRedactBody(t *testing.T) {
+	testCases := map[string]struct {
+		path     string
+		body     string
+		expected string
+	}{
+		"acl-token": {
+			path:     "/v1/acl/token",
+			body:     `{"AccessorID":"a5f3a2b1","SecretID":"9f3e1c07-5b1e-4c3b-8a5e-1e1b0c9a2d4f","Description":"foo"}`,
+			expected: `{"AccessorID":"a5f3a2b1","SecretID":"<redacted>","Description":"foo"}`,
+		},
+		"indented": {
+			path:     "/v1/acl/login",
+			body:     "{\n  \"AuthMethod\": \"jwt\",\n  \"BearerToken\": \"eyJhbGciOi\\\"JSUzI1NiJ9\"\n}",
+			expected: "{\n  \"AuthMethod\": \"jwt\",\n  \"BearerToken\": \"<redacted>\"\n}",
+		},
+		"peering-token": {
+			path:     "/v1/peering/token",
+			body:     `{"PeeringToken":"ZXlKRFFTST"}`,
+			expected: `{"PeeringToken":"<redacted>"}`,
+		},
+		"prepared-query-token": {
+			path:     "/v1/query",
+			body:     `{"Name":"foo","Token":"b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a","Service":{"Service":"web"}}`,
+			expected: `{"Name":"foo","Token":"<redacted>","Service":{"Service":"web"}}`,
+		},
+		"agent-token": {
+			path:     "/v1/agent/token/default",
+			body:     `{"Token":"b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a"}`,
+			expected: `{"Token":"<redacted>"}`,
+		},
+		"ca-provider-token": {
+			path:     "/v1/connect/ca/configuration",
+			body:     `{"Provider":"vault","Config":{"Address":"https://vault:8200","Token":"hvs.CAESI"}}`,
+			expected: `{"Provider":"vault","Config":{"Address":"https://vault:8200","Token":"<redacted>"}}`,
+		},
+		"auth-method-kubernetes": {
+			path:     "/v1/acl/auth-method",
+			body:     `{"Name":"k8s","Type":"kubernetes","Config":{"Host":"https://k8s:443","ServiceAccountJWT":"eyJhbGciOi"}}`,
+			expected: `{"Name":"k8s","Type":"kubernetes","Config":{"Host":"https://k8s:443","ServiceAccountJWT":"<redacted>"}}`,
+		},
+		"auth-method-oidc": {
+			path:     "/v1/acl/auth-method",
+			body:     `{"Name":"oidc","Type":"oidc","Config":{"OIDCClientID":"consul","OIDCClientSecret":"s3cr3t"}}`,
+			expected: `{"Name":"oidc","Type":"oidc","Config":{"OIDCClientID":"consul","OIDCClientSecret":"<redacted>"}}`,
+		},
+		"kv": {
+			path:     "/v1/kv/secret/password",
+			body:     `hunter2`,
+			expected: `<redacted>`,
+		},
+		"txn": {
+			path:     "/v1/txn",
+			body:     `[{"KV":{"Verb":"set","Key":"foo","Value":"YmFy"}}]`,
+			expected: `<redacted>`,
+		},
+		"truncated-secret": {
+			path:     "/v1/acl/token",
+			body:     `{"AccessorID":"a5f3a2b1","SecretID":"9f3e1c07-5b1e`,
+			expected: `{"AccessorID":"a5f3a2b1","SecretID":"<redacted>`,
+		},
+		"nothing-to-redact": {
+			path:     "/v1/catalog/services",
+			body:     `{"consul":[]}`,
+			expected: `{"consul":[]}`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := redactBody(tc.path, []byte(tc.body)); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Consul-Token", "secret")
+	h.Set("Authorization", "Basic dXNlcjpwYXNz")
+	h.Set("Content-Type", "application/json")
+
+	got := redactHeaders(h)
+	if got.Get("X-Consul-Token") != redacted || got.Get("Authorization") != redacted {
+		t.Fatalf("expected the secrets to be redacted, got %v", got)
+	}
+	if got.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected the other headers to be kept, got %v", got)
+	}
+	if h.Get("X-Consul-Token") != "secret" {
+		t.Fatal("the original headers must not be modified")
+	}
+}
+
+func TestLoggingTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"AccessorID":"accessor","SecretID":"response-secret"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	var buf bytes.Buffer
+	config := &Config{
+		Address: strings.TrimPrefix(server.URL, "http://"),
+		Token:   "request-secret",
+		logger: hclog.New(&hclog.LoggerOptions{
+			Level:  hclog.Trace,
+			Output: &buf,
+		}),
+	}
+	client, err := config.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	token, _, err := client.ACL().TokenCreate(&consulapi.ACLToken{SecretID: "created-secret"}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if token.SecretID != "response-secret" {
+		t.Fatalf("the response must not be modified, got %q", token.SecretID)
+	}
+
+	logs := buf.String()
+	for _, secret := range []string{"request-secret", "response-secret", "created-secret"} {
+		if strings.Contains(logs, secret) {
+			t.Errorf("secret %q found in the logs:\n%s", secret, logs)
+		}
+	}
+	for _, expected := range []string{"Request completed", "method=PUT", "path=/v1/acl/token", "status=200", "latency="} {
+		if !strings.Contains(logs, expected) {
+			t.Errorf("expected %q in the logs:\n%s", expected, logs)
+		}
+	}
+}
+
+func TestLoggingTransport_largeBodies(t *testing.T) {
+	large := `{"Value":"` + strings.Repeat("a", 2*maxLoggedBodySize) + `"}`
+	snapshot := "snapshot-content"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v1/snapshot" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte(snapshot))
+			return
+		}
+		_, _ = w.Write([]byte(large))
+	}))
+	t.Cleanup(server.Close)
+
+	var buf bytes.Buffer
+	client := &http.Client{
+		Transport: &loggingTransport{
+			RoundTripper: http.DefaultTransport,
+			logger: hclog.New(&hclog.LoggerOptions{
+				Level:  hclog.Trace,
+				Output: &buf,
+			}),
+		},
+	}
+
+	for _, tc := range []struct {
+		path     string
+		expected string
+	}{
+		{"/v1/catalog/services", large},
+		{"/v1/snapshot", snapshot},
+	} {
+		resp, err := client.Get(server.URL + tc.path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(body) != tc.expected {
+			t.Fatalf("the response to %s must not be modified, got %d bytes", tc.path, len(body))
+		}
+	}
+
+	logs := buf.String()
+	if strings.Contains(logs, snapshot) {
+		t.Errorf("the snapshot must not be logged:\n%s", logs)
+	}
+	if strings.Contains(logs, strings.Repeat("a", maxLoggedBodySize)) {
+		t.Errorf("the large body must be truncated:\n%s", logs)
+	}
+	if !strings.Contains(logs, "...<truncated>") {
+		t.Errorf("expected the truncation to be logged:\n%s", logs)
+	}
+}
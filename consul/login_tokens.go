@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"log"
+	"sync"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// loginTokens keeps track of the ACL tokens created by the provider when
+// logging in with an auth method so they can be destroyed once Terraform is
+// done with the provider.
+var loginTokens = &loginTokenTracker{}
+
+type loginToken struct {
+	client   *consulapi.Client
+	secretID string
+}
+
+type loginTokenTracker struct {
+	mu     sync.Mutex
+	tokens []loginToken
+}
+
+func (t *loginTokenTracker) track(client *consulapi.Client, secretID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tokens = append(t.tokens, loginToken{client: client, secretID: secretID})
+}
+
+func (t *loginTokenTracker) logout() {
+	t.mu.Lock()
+	tokens := t.tokens
+	t.tokens = nil
+	t.mu.Unlock()
+
+	for _, token := range tokens {
+		_, err := token.client.ACL().Logout(&consulapi.WriteOptions{Token: token.secretID})
+		if err != nil {
+			log.Printf("[WARN] Failed to logout the auth method token: %v", err)
+			continue
+		}
+		log.Printf("[DEBUG] Logged out the auth method token")
+	}
+}
+
+// LogoutLoginTokens destroys all the tokens created by the provider when
+// logging in with an auth method while logout_on_exit was set. It is meant to
+// be called once the plugin has stopped serving requests.
+func LogoutLoginTokens() {
+	loginTokens.logout()
+}
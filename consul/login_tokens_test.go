@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+type fakeLoginServer struct {
+	mu      sync.Mutex
+	logouts []string
+}
+
+func (s *fakeLoginServer) handler(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/v1/acl/login":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"SecretID": "login-secret-id",
+		})
+	case "/v1/acl/logout":
+		s.mu.Lock()
+		s.logouts = append(s.logouts, req.Header.Get("X-Consul-Token"))
+		s.mu.Unlock()
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func testConfigureWithLogin(t *testing.T, logoutOnExit bool) *fakeLoginServer {
+	t.Helper()
+
+	fake := &fakeLoginServer{}
+	server := httptest.NewServer(http.HandlerFunc(fake.handler))
+	t.Cleanup(server.Close)
+
+	raw := map[string]interface{}{
+		"address":        strings.TrimPrefix(server.URL, "http://"),
+		"datacenter":     "dc1",
+		"logout_on_exit": logoutOnExit,
+		"auth_login_jwt": []interface{}{
+			map[string]interface{}{
+				"auth_method":  "jwt",
+				"bearer_token": "jwt-token",
+			},
+		},
+	}
+
+	if err := Provider().Configure(terraform.NewResourceConfigRaw(raw)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return fake
+}
+
+func TestLogoutLoginTokens(t *testing.T) {
+	fake := testConfigureWithLogin(t, true)
+
+	LogoutLoginTokens()
+
+	if len(fake.logouts) != 1 || fake.logouts[0] != "login-secret-id" {
+		t.Fatalf("expected a single logout with the login token, got %v", fake.logouts)
+	}
+
+	// Tokens must only be logged out once
+	LogoutLoginTokens()
+	if len(fake.logouts) != 1 {
+		t.Fatalf("expected a single logout, got %d", len(fake.logouts))
+	}
+}
+
+func TestLogoutLoginTokens_disabled(t *testing.T) {
+	fake := testConfigureWithLogin(t, false)
+
+	LogoutLoginTokens()
+
+	if len(fake.logouts) != 0 {
+		t.Fatalf("expected no logout, got %v", fake.logouts)
+	}
+}
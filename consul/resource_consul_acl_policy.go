@@ -10,6 +10,7 @@ import (
 
 	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 )
 
 func resourceConsulACLPolicy() *schema.Resource {
@@ -22,6 +23,23 @@ func resourceConsulACLPolicy() *schema.Resource {
 			State: schema.ImportStatePassthrough,
 		},
 
+		CustomizeDiff: func(d *schema.ResourceDiff, _ interface{}) error {
+			// The rules are rendered from the rule blocks during the plan so
+			// that the new rules are displayed
+			blocks := d.Get("rule").(*schema.Set).List()
+			if d.HasChange("rule") && d.NewValueKnown("rule") && len(blocks) > 0 {
+				rules, err := renderACLPolicyRules(blocks)
+				if err != nil {
+					return err
+				}
+				return d.SetNew("rules", rules)
+			}
+			if d.HasChange("rules") {
+				return d.SetNewComputed("rule")
+			}
+			return nil
+		},
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:        schema.TypeString,
@@ -34,9 +52,41 @@ func resourceConsulACLPolicy() *schema.Resource {
 				Description: "The ACL policy description.",
 			},
 			"rules": {
-				Type:        schema.TypeString,
-				Required:    true,
-				Description: "The ACL policy rules.",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ExactlyOneOf:     []string{"rules", "rule"},
+				Description:      "The ACL policy rules, in HCL or JSON. They are validated during the plan and the rules granting the same permissions are considered equal regardless of their syntax or formatting.",
+				ValidateFunc:     validateACLPolicyRules,
+				DiffSuppressFunc: diffACLPolicyRules,
+			},
+			"rule": {
+				Type:         schema.TypeSet,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"rules", "rule"},
+				Description:  "The ACL policy rules as structured blocks, an alternative to `rules`. The canonical rules rendered from the blocks are stored in `rules`.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"resource_type": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "The type of the resource the rule applies to.",
+							ValidateFunc: validation.StringInSlice(aclPolicyRuleTypes, false),
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The name, or the prefix for the `_prefix` resource types, of the resources the rule applies to. Must not be set for `acl`, `keyring`, `mesh`, `operator` and `peering`.",
+						},
+						"policy": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "The access level granted by the rule, either `read`, `write`, `deny` or `list`.",
+							ValidateFunc: validation.StringInSlice(aclPolicyDispositions, false),
+						},
+					},
+				},
 			},
 			"datacenters": {
 				Type:        schema.TypeSet,
@@ -112,6 +162,14 @@ func resourceConsulACLPolicyRead(d *schema.ResourceData, meta interface{}) error
 	sw.set("name", aclPolicy.Name)
 	sw.set("description", aclPolicy.Description)
 	sw.set("rules", aclPolicy.Rules)
+
+	// The rules that cannot be represented using rule blocks can only be
+	// managed with the rules attribute
+	rules, ok := aclPolicyRuleBlocks(aclPolicy.Rules)
+	if !ok {
+		rules = []interface{}{}
+	}
+	sw.set("rule", rules)
 	sw.set("datacenters", aclPolicy.Datacenters)
 	sw.set("namespace", aclPolicy.Namespace)
 	sw.set("partition", aclPolicy.Partition)
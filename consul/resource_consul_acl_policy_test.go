@@ -100,6 +100,41 @@ func TestAccConsulACLPolicy_import(t *testing.T) {
 	})
 }
 
+func TestAccConsulACLPolicy_ruleBlocks(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers:    providers,
+		CheckDestroy: testAccCheckConsulACLPolicyDestroy(client),
+		Steps: []resource.TestStep{
+			{
+				Config: testResourceACLPolicyConfigRuleBlocks,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("consul_acl_policy.test", "rule.#", "3"),
+					resource.TestCheckResourceAttr("consul_acl_policy.test", "rules", "operator = \"read\"\n\nkey_prefix \"vault/\" {\n  policy = \"write\"\n}\n\nservice \"web\" {\n  policy = \"read\"\n}\n"),
+				),
+			},
+			{
+				// Switching to the equivalent raw rules must not produce a diff
+				Config:   testResourceACLPolicyConfigRuleBlocksRaw,
+				PlanOnly: true,
+			},
+			{
+				ResourceName:      "consul_acl_policy.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				Config: testResourceACLPolicyConfigBasic,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("consul_acl_policy.test", "rules", "node_prefix \"\" { policy = \"read\" }"),
+					resource.TestCheckResourceAttr("consul_acl_policy.test", "rule.#", "1"),
+				),
+			},
+		},
+	})
+}
+
 func TestAccConsulACLPolicy_NamespaceCE(t *testing.T) {
 	providers, _ := startTestServer(t)
 
@@ -143,6 +178,38 @@ resource "consul_acl_policy" "test" {
 	datacenters = [ "dc1" ]
 }`
 
+const testResourceACLPolicyConfigRuleBlocks = `
+resource "consul_acl_policy" "test" {
+	name = "test-policy"
+
+	rule {
+		resource_type = "service"
+		name          = "web"
+		policy        = "read"
+	}
+
+	rule {
+		resource_type = "operator"
+		policy        = "read"
+	}
+
+	rule {
+		resource_type = "key_prefix"
+		name          = "vault/"
+		policy        = "write"
+	}
+}`
+
+const testResourceACLPolicyConfigRuleBlocksRaw = `
+resource "consul_acl_policy" "test" {
+	name  = "test-policy"
+	rules = <<-EOT
+	operator = "read"
+	service "web" { policy = "read" }
+	key_prefix "vault/" { policy = "write" }
+	EOT
+}`
+
 const testResourceACLPolicyNamespaceCE = `
 resource "consul_acl_policy" "test" {
   name      = "test"
@@ -23,8 +23,9 @@ func resourceConsulACLToken() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
+		CustomizeDiff: resourceConsulACLTokenCustomizeDiff,
 
-		Description: "The `consul_acl_token` resource writes an ACL token into Consul.\n\n~> **NOTE:** The `consul_acl_token` resource does not save the secret ID of the generated token to the Terraform state to avoid leaking it when it is not needed. If you need to get the secret ID after creating the ACL token you can use the [`consul_acl_token_secret_id`](/docs/providers/consul/d/consul_acl_token_secret_id.html) datasource.",
+		Description: "The `consul_acl_token` resource writes an ACL token into Consul.\n\n~> **NOTE:** By default the `consul_acl_token` resource does not save the secret ID generated by Consul to the Terraform state to avoid leaking it when it is not needed. If you need to get the secret ID after creating the ACL token you can either set `store_secret_id_in_state` or use the [`consul_acl_token_secret_id`](/docs/providers/consul/d/consul_acl_token_secret_id.html) datasource.",
 
 		Schema: map[string]*schema.Schema{
 			"accessor_id": {
@@ -34,6 +35,21 @@ func resourceConsulACLToken() *schema.Resource {
 				Optional:    true,
 				Description: "The uuid of the token. If omitted, Consul will generate a random uuid.",
 			},
+			"secret_id": {
+				Type:         schema.TypeString,
+				ForceNew:     true,
+				Computed:     true,
+				Optional:     true,
+				Sensitive:    true,
+				ValidateFunc: validation.IsUUID,
+				Description:  "The secret ID of the token, for example to use a secret generated by Vault. If omitted, Consul will generate a random uuid. The secret ID given in this argument is always saved to the state and changing it replaces the token, unless it is the secret ID of the existing token, for example after an import.",
+			},
+			"store_secret_id_in_state": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether the secret ID generated by Consul should be saved to the state and exported in `secret_id`. Once saved, it is kept in the state until the token is replaced.",
+			},
 			"description": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -168,6 +184,7 @@ func resourceConsulACLTokenCreate(d *schema.ResourceData, meta interface{}) erro
 	log.Printf("[DEBUG] Creating ACL token")
 
 	aclToken := getToken(d)
+	aclToken.SecretID = d.Get("secret_id").(string)
 
 	token, _, err := client.ACL().TokenCreate(aclToken, wOpts)
 	if err != nil {
@@ -238,8 +255,19 @@ func resourceConsulACLTokenRead(d *schema.ResourceData, meta interface{}) error
 		}
 	}
 
+	// The secret ID generated by Consul is only saved when explicitly
+	// requested to avoid leaking it, the one given in the configuration is
+	// kept so that changing it produces a diff
+	secretID := d.Get("secret_id").(string)
+	if d.Get("store_secret_id_in_state").(bool) {
+		secretID = aclToken.SecretID
+	} else if secretID != aclToken.SecretID {
+		secretID = ""
+	}
+
 	sw := newStateWriter(d)
 	sw.set("accessor_id", aclToken.AccessorID)
+	sw.set("secret_id", secretID)
 	sw.set("description", aclToken.Description)
 	sw.set("policies", policies)
 	sw.set("roles", roles)
@@ -254,6 +282,38 @@ func resourceConsulACLTokenRead(d *schema.ResourceData, meta interface{}) error
 	return sw.error()
 }
 
+// resourceConsulACLTokenCustomizeDiff clears the diff on the secret ID of an
+// existing token when it is not in the state, because the token has just been
+// imported or was created without secret_id, and the configured secret ID is
+// the one of the token, since it would otherwise force its replacement.
+func resourceConsulACLTokenCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.HasChange("secret_id") || !d.NewValueKnown("secret_id") {
+		return nil
+	}
+
+	old, new := d.GetChange("secret_id")
+	if old.(string) != "" || new.(string) == "" {
+		return nil
+	}
+
+	config := meta.(*Config)
+	qOpts := &consulapi.QueryOptions{
+		Datacenter: config.Datacenter,
+		Namespace:  d.Get("namespace").(string),
+		Partition:  d.Get("partition").(string),
+		Token:      config.token(),
+	}
+	token, _, err := config.client.ACL().TokenRead(d.Id(), qOpts)
+	if err != nil {
+		return fmt.Errorf("failed to read token '%s': %v", d.Id(), err)
+	}
+
+	if token.SecretID == new.(string) {
+		return d.Clear("secret_id")
+	}
+	return nil
+}
+
 func getTemplateVariables(templatedPolicy *consulapi.ACLTemplatedPolicy) []map[string]interface{} {
 	if templatedPolicy == nil || templatedPolicy.TemplateVariables == nil {
 		return nil
@@ -0,0 +1,411 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func resourceConsulACLTokenRotation() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceConsulACLTokenRotationCreate,
+		Read:   resourceConsulACLTokenRotationRead,
+		Update: resourceConsulACLTokenRotationUpdate,
+		Delete: resourceConsulACLTokenRotationDelete,
+
+		CustomizeDiff: resourceConsulACLTokenRotationCustomizeDiff,
+
+		Description: "The `consul_acl_token_rotation` resource manages a clone of an existing ACL token that is replaced by a new clone when `rotation_period` elapses or when `keepers` change. The replaced clones are kept alive during `grace_period` so that the applications using them have time to pick up the new one, and are deleted by the first apply after their grace period, even when the token is rotated again in the meantime.\n\n~> **NOTE:** Terraform only rotates the token when it is applied, the rotation must be scheduled by running `terraform apply` regularly.",
+
+		Schema: map[string]*schema.Schema{
+			"source_accessor_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The accessor ID of the token to clone, usually managed by a `consul_acl_token` resource. Its policies, roles and identities are copied to each new token.",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The description of the tokens created by the rotation. Changing it rotates the token.",
+			},
+			"rotation_period": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "How long a token is used before being rotated, for example `2160h` for about three months. When omitted the token is only rotated when `keepers` change.",
+				ValidateFunc: makeValidationFunc("rotation_period", []interface{}{validateDurationMin("1m")}),
+			},
+			"grace_period": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "24h",
+				Description:  "How long the previous token is kept after a rotation before being deleted.",
+				ValidateFunc: makeValidationFunc("grace_period", []interface{}{validateDurationMin("0s")}),
+			},
+			"keepers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Arbitrary values that rotate the token when they change.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"pgp_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Either a base-64 encoded PGP public key, or a keybase username in the form `keybase:some_person_that_exists`. When set the secret ID of the current token is exported encrypted in `encrypted_secret_id` instead of `secret_id`.",
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The namespace of the token to clone.",
+			},
+			"partition": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The partition of the token to clone.",
+			},
+
+			// Out parameters
+			"accessor_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The accessor ID of the current token.",
+			},
+			"secret_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The secret ID of the current token, empty when `pgp_key` is set.",
+			},
+			"encrypted_secret_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The secret ID of the current token encrypted with `pgp_key`.",
+			},
+			"previous_accessor_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The accessor ID of the last token replaced by a rotation while it is in its grace period, empty otherwise.",
+			},
+			"previous_tokens": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The tokens replaced by a rotation that are still in their grace period, from the oldest to the most recent.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"accessor_id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The accessor ID of the token.",
+						},
+						"superseded_at": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The time the token was replaced, in RFC 3339 format. It is deleted once `grace_period` has elapsed since then.",
+						},
+					},
+				},
+			},
+			"rotated_at": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time of the last rotation, in RFC 3339 format.",
+			},
+		},
+	}
+}
+
+func resourceConsulACLTokenRotationCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+
+	now := time.Now()
+	rotatedAt := d.Get("rotated_at").(string)
+
+	rotate := d.HasChange("keepers") || d.HasChange("description")
+	if !rotate {
+		var err error
+		rotate, err = aclTokenRotationElapsed(rotatedAt, d.Get("rotation_period").(string), now)
+		if err != nil {
+			return fmt.Errorf("failed to check the rotation period: %v", err)
+		}
+	}
+
+	if rotate {
+		for _, attr := range []string{"accessor_id", "secret_id", "encrypted_secret_id", "previous_accessor_id", "previous_tokens", "rotated_at"} {
+			if err := d.SetNewComputed(attr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	previous := d.Get("previous_tokens").([]interface{})
+	kept, expired, err := aclTokenRotationExpired(previous, d.Get("grace_period").(string), now)
+	if err != nil {
+		return fmt.Errorf("failed to check the grace period: %v", err)
+	}
+	if len(expired) > 0 {
+		if err := d.SetNew("previous_tokens", kept); err != nil {
+			return err
+		}
+		if err := d.SetNew("previous_accessor_id", lastACLTokenRotationPrevious(kept)); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("pgp_key") {
+		if err := d.SetNewComputed("secret_id"); err != nil {
+			return err
+		}
+		return d.SetNewComputed("encrypted_secret_id")
+	}
+
+	return nil
+}
+
+// aclTokenRotationElapsed reports whether period has elapsed since the token
+// was rotated at rotatedAt. An empty period never elapses.
+func aclTokenRotationElapsed(rotatedAt, period string, now time.Time) (bool, error) {
+	if period == "" {
+		return false, nil
+	}
+
+	duration, err := time.ParseDuration(period)
+	if err != nil {
+		return false, err
+	}
+	t, err := time.Parse(time.RFC3339, rotatedAt)
+	if err != nil {
+		return false, err
+	}
+	return !now.Before(t.Add(duration)), nil
+}
+
+// aclTokenRotationExpired splits the previous tokens between the ones still in
+// their grace period and the ones to delete.
+func aclTokenRotationExpired(previous []interface{}, gracePeriod string, now time.Time) ([]interface{}, []interface{}, error) {
+	kept := []interface{}{}
+	expired := []interface{}{}
+	for _, raw := range previous {
+		token := raw.(map[string]interface{})
+		elapsed, err := aclTokenRotationElapsed(token["superseded_at"].(string), gracePeriod, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		if elapsed {
+			expired = append(expired, token)
+		} else {
+			kept = append(kept, token)
+		}
+	}
+	return kept, expired, nil
+}
+
+func lastACLTokenRotationPrevious(previous []interface{}) string {
+	if len(previous) == 0 {
+		return ""
+	}
+	return previous[len(previous)-1].(map[string]interface{})["accessor_id"].(string)
+}
+
+func resourceConsulACLTokenRotationCreate(d *schema.ResourceData, meta interface{}) error {
+	if err := rotateACLToken(d, meta); err != nil {
+		return err
+	}
+	return resourceConsulACLTokenRotationRead(d, meta)
+}
+
+func resourceConsulACLTokenRotationRead(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, _ := getClient(d, meta)
+
+	id := d.Id()
+	log.Printf("[DEBUG] Reading ACL token %q", id)
+
+	aclToken, _, err := client.ACL().TokenRead(id, qOpts)
+	if err != nil {
+		if strings.Contains(err.Error(), "ACL not found") {
+			log.Printf("[WARN] ACL token %q not found, removing from state", id)
+			d.SetId("")
+			return nil
+		}
+		return fmt.Errorf("failed to read token '%s': %v", id, err)
+	}
+
+	previous := []interface{}{}
+	for _, raw := range d.Get("previous_tokens").([]interface{}) {
+		token := raw.(map[string]interface{})
+		accessorID := token["accessor_id"].(string)
+		_, _, err := client.ACL().TokenRead(accessorID, qOpts)
+		if err != nil {
+			if !strings.Contains(err.Error(), "ACL not found") {
+				return fmt.Errorf("failed to read token '%s': %v", accessorID, err)
+			}
+			log.Printf("[WARN] Previous ACL token %q not found", accessorID)
+			continue
+		}
+		previous = append(previous, token)
+	}
+
+	sw := newStateWriter(d)
+	sw.set("accessor_id", aclToken.AccessorID)
+	sw.set("previous_tokens", previous)
+	sw.set("previous_accessor_id", lastACLTokenRotationPrevious(previous))
+	sw.set("namespace", aclToken.Namespace)
+	sw.set("partition", aclToken.Partition)
+
+	return sw.error()
+}
+
+func resourceConsulACLTokenRotationUpdate(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, wOpts := getClient(d, meta)
+
+	// The rotation time is only unknown when the token must be rotated
+	if d.HasChange("rotated_at") {
+		if err := rotateACLToken(d, meta); err != nil {
+			return err
+		}
+		return resourceConsulACLTokenRotationRead(d, meta)
+	}
+
+	if d.HasChange("previous_tokens") {
+		o, n := d.GetChange("previous_tokens")
+		kept := map[string]bool{}
+		for _, raw := range n.([]interface{}) {
+			kept[raw.(map[string]interface{})["accessor_id"].(string)] = true
+		}
+		for _, raw := range o.([]interface{}) {
+			accessorID := raw.(map[string]interface{})["accessor_id"].(string)
+			if kept[accessorID] {
+				continue
+			}
+			if err := deleteACLToken(client, accessorID, wOpts); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.HasChange("pgp_key") {
+		aclToken, _, err := client.ACL().TokenRead(d.Id(), qOpts)
+		if err != nil {
+			return fmt.Errorf("failed to read token '%s': %v", d.Id(), err)
+		}
+		if err := setACLTokenRotationSecretID(d, aclToken); err != nil {
+			return err
+		}
+	}
+
+	return resourceConsulACLTokenRotationRead(d, meta)
+}
+
+func resourceConsulACLTokenRotationDelete(d *schema.ResourceData, meta interface{}) error {
+	client, _, wOpts := getClient(d, meta)
+
+	ids := []string{}
+	for _, raw := range d.Get("previous_tokens").([]interface{}) {
+		ids = append(ids, raw.(map[string]interface{})["accessor_id"].(string))
+	}
+	ids = append(ids, d.Id())
+
+	for _, id := range ids {
+		if err := deleteACLToken(client, id, wOpts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateACLToken clones the source token to create the new current token. The
+// current token is kept as a previous token during its grace period, and the
+// previous tokens whose grace period is over are deleted.
+func rotateACLToken(d *schema.ResourceData, meta interface{}) error {
+	client, _, wOpts := getClient(d, meta)
+
+	source := d.Get("source_accessor_id").(string)
+	log.Printf("[DEBUG] Cloning ACL token %q", source)
+
+	aclToken, _, err := client.ACL().TokenClone(source, d.Get("description").(string), wOpts)
+	if err != nil {
+		return fmt.Errorf("failed to clone ACL token %q: %v", source, err)
+	}
+	log.Printf("[DEBUG] Cloned ACL token %q to %q", source, aclToken.AccessorID)
+
+	// The attributes are unknown during a rotation, their old values hold the
+	// tokens in use
+	current, _ := d.GetChange("accessor_id")
+	oldPrevious, _ := d.GetChange("previous_tokens")
+
+	now := time.Now().UTC()
+	previous, expired, err := aclTokenRotationExpired(oldPrevious.([]interface{}), d.Get("grace_period").(string), now)
+	if err != nil {
+		return fmt.Errorf("failed to check the grace period: %v", err)
+	}
+	if current.(string) != "" {
+		previous = append(previous, map[string]interface{}{
+			"accessor_id":   current.(string),
+			"superseded_at": now.Format(time.RFC3339),
+		})
+	}
+
+	d.SetId(aclToken.AccessorID)
+
+	sw := newStateWriter(d)
+	sw.set("previous_tokens", previous)
+	sw.set("previous_accessor_id", lastACLTokenRotationPrevious(previous))
+	sw.set("accessor_id", aclToken.AccessorID)
+	sw.set("rotated_at", now.Format(time.RFC3339))
+	if err := sw.error(); err != nil {
+		return err
+	}
+	if err := setACLTokenRotationSecretID(d, aclToken); err != nil {
+		return err
+	}
+
+	for _, raw := range expired {
+		if err := deleteACLToken(client, raw.(map[string]interface{})["accessor_id"].(string), wOpts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setACLTokenRotationSecretID(d *schema.ResourceData, aclToken *consulapi.ACLToken) error {
+	var secretID, encrypted string
+	if pgpKey := d.Get("pgp_key").(string); pgpKey != "" {
+		var err error
+		encrypted, err = encryptACLTokenSecretID(pgpKey, aclToken.SecretID)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt the secret ID of ACL token %q: %v", aclToken.AccessorID, err)
+		}
+	} else {
+		secretID = aclToken.SecretID
+	}
+
+	sw := newStateWriter(d)
+	sw.set("secret_id", secretID)
+	sw.set("encrypted_secret_id", encrypted)
+	return sw.error()
+}
+
+// deleteACLToken deletes the token, ignoring the tokens that have already been
+// deleted.
+func deleteACLToken(client *consulapi.Client, id string, wOpts *consulapi.WriteOptions) error {
+	log.Printf("[DEBUG] Deleting ACL token %q", id)
+	_, err := client.ACL().TokenDelete(id, wOpts)
+	if err != nil && !strings.Contains(err.Error(), "ACL not found") {
+		return fmt.Errorf("error deleting ACL token %q: %s", id, err)
+	}
+	return nil
+}
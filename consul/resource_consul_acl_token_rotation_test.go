@@ -0,0 +1,247 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccConsulACLTokenRotation_basic(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	var first, second string
+	saveAccessorID := func(accessorID *string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			rs, ok := s.RootModule().Resources["consul_acl_token_rotation.test"]
+			if !ok {
+				return fmt.Errorf("consul_acl_token_rotation.test not found")
+			}
+			*accessorID = rs.Primary.Attributes["accessor_id"]
+			return nil
+		}
+	}
+	checkTokenAttr := func(attr string, accessorID *string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			return resource.TestCheckResourceAttr("consul_acl_token_rotation.test", attr, *accessorID)(s)
+		}
+	}
+	checkExists := func(accessorID *string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			_, _, err := client.ACL().TokenRead(*accessorID, nil)
+			return err
+		}
+	}
+	checkDeleted := func(accessorID *string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			token, _, _ := client.ACL().TokenRead(*accessorID, nil)
+			if token != nil {
+				return fmt.Errorf("ACL token %q still exists", *accessorID)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		Providers:    providers,
+		CheckDestroy: testAccCheckConsulACLTokenRotationDestroy(client),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testResourceACLTokenRotationConfig, "v1", "24h"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("consul_acl_token_rotation.test", "accessor_id"),
+					resource.TestCheckResourceAttrSet("consul_acl_token_rotation.test", "rotated_at"),
+					resource.TestCheckResourceAttr("consul_acl_token_rotation.test", "previous_accessor_id", ""),
+					resource.TestCheckResourceAttr("consul_acl_token_rotation.test", "encrypted_secret_id", ""),
+					testAccCheckTokenExistsAndValidUUID("consul_acl_token_rotation.test", "secret_id"),
+					testAccCheckConsulACLTokenRotationPolicies(client),
+					saveAccessorID(&first),
+				),
+			},
+			{
+				// Changing the keepers rotates the token and keeps the
+				// previous one during the grace period
+				Config: fmt.Sprintf(testResourceACLTokenRotationConfig, "v2", "24h"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					checkTokenAttr("previous_accessor_id", &first),
+					resource.TestCheckResourceAttr("consul_acl_token_rotation.test", "previous_tokens.#", "1"),
+					testAccCheckConsulACLTokenRotationPolicies(client),
+					saveAccessorID(&second),
+				),
+			},
+			{
+				Config:   fmt.Sprintf(testResourceACLTokenRotationConfig, "v2", "24h"),
+				PlanOnly: true,
+			},
+			{
+				// Rotating again during the grace period keeps both previous
+				// tokens
+				Config: fmt.Sprintf(testResourceACLTokenRotationConfig, "v3", "24h"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					checkTokenAttr("previous_accessor_id", &second),
+					resource.TestCheckResourceAttr("consul_acl_token_rotation.test", "previous_tokens.#", "2"),
+					checkTokenAttr("previous_tokens.0.accessor_id", &first),
+					checkTokenAttr("previous_tokens.1.accessor_id", &second),
+					checkExists(&first),
+				),
+			},
+			{
+				// The grace period is over, the previous tokens are deleted
+				Config: fmt.Sprintf(testResourceACLTokenRotationConfig, "v3", "0s"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("consul_acl_token_rotation.test", "previous_accessor_id", ""),
+					resource.TestCheckResourceAttr("consul_acl_token_rotation.test", "previous_tokens.#", "0"),
+					checkDeleted(&first),
+					checkDeleted(&second),
+				),
+			},
+		},
+	})
+}
+
+func TestACLTokenRotationElapsed(t *testing.T) {
+	rotatedAt := "2026-01-01T00:00:00Z"
+	start, _ := time.Parse(time.RFC3339, rotatedAt)
+
+	cases := map[string]struct {
+		period  string
+		now     time.Time
+		elapsed bool
+	}{
+		"no period": {
+			period: "",
+			now:    start.Add(10000 * time.Hour),
+		},
+		"not elapsed": {
+			period: "2160h",
+			now:    start.Add(2159 * time.Hour),
+		},
+		"elapsed": {
+			period:  "2160h",
+			now:     start.Add(2160 * time.Hour),
+			elapsed: true,
+		},
+		"no grace period": {
+			period:  "0s",
+			now:     start,
+			elapsed: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			elapsed, err := aclTokenRotationElapsed(rotatedAt, tc.period, tc.now)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if elapsed != tc.elapsed {
+				t.Fatalf("expected %v, got %v", tc.elapsed, elapsed)
+			}
+		})
+	}
+
+	if _, err := aclTokenRotationElapsed("", "1h", start); err == nil {
+		t.Fatal("expected an error for an invalid rotation time")
+	}
+}
+
+func TestACLTokenRotationExpired(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2026-01-02T00:00:00Z")
+	previous := []interface{}{
+		map[string]interface{}{"accessor_id": "a", "superseded_at": "2026-01-01T00:00:00Z"},
+		map[string]interface{}{"accessor_id": "b", "superseded_at": "2026-01-01T12:00:00Z"},
+	}
+
+	kept, expired, err := aclTokenRotationExpired(previous, "18h", now)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(expired) != 1 || expired[0].(map[string]interface{})["accessor_id"] != "a" {
+		t.Fatalf("bad expired tokens: %#v", expired)
+	}
+	if len(kept) != 1 || lastACLTokenRotationPrevious(kept) != "b" {
+		t.Fatalf("bad kept tokens: %#v", kept)
+	}
+
+	kept, expired, err = aclTokenRotationExpired(previous, "0s", now)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(kept) != 0 || len(expired) != 2 || lastACLTokenRotationPrevious(kept) != "" {
+		t.Fatalf("expected all the tokens to expire, kept %#v", kept)
+	}
+}
+
+func testAccCheckConsulACLTokenRotationDestroy(client *consulapi.Client) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "consul_acl_token_rotation" {
+				continue
+			}
+			ids := []string{rs.Primary.ID}
+			for k, v := range rs.Primary.Attributes {
+				if strings.HasPrefix(k, "previous_tokens.") && strings.HasSuffix(k, ".accessor_id") {
+					ids = append(ids, v)
+				}
+			}
+			for _, id := range ids {
+				if id == "" {
+					continue
+				}
+				token, _, _ := client.ACL().TokenRead(id, nil)
+				if token != nil {
+					return fmt.Errorf("ACL token %q still exists", id)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// testAccCheckConsulACLTokenRotationPolicies checks that the current token has
+// the policies of the source token.
+func testAccCheckConsulACLTokenRotationPolicies(client *consulapi.Client) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["consul_acl_token_rotation.test"]
+		if !ok {
+			return fmt.Errorf("consul_acl_token_rotation.test not found")
+		}
+		token, _, err := client.ACL().TokenRead(rs.Primary.Attributes["accessor_id"], nil)
+		if err != nil {
+			return err
+		}
+		if len(token.Policies) != 1 || token.Policies[0].Name != "test-token-rotation" {
+			return fmt.Errorf("wrong policies: %#v", token.Policies)
+		}
+		return nil
+	}
+}
+
+const testResourceACLTokenRotationConfig = `
+resource "consul_acl_policy" "test" {
+	name  = "test-token-rotation"
+	rules = "node_prefix \"\" { policy = \"read\" }"
+}
+
+resource "consul_acl_token" "source" {
+	description = "source"
+	policies    = [consul_acl_policy.test.name]
+}
+
+resource "consul_acl_token_rotation" "test" {
+	source_accessor_id = consul_acl_token.source.id
+	description        = "rotated"
+	grace_period       = "%[2]s"
+
+	keepers = {
+		version = "%[1]s"
+	}
+}
+`
@@ -4,7 +4,11 @@
 package consul
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	consulapi "github.com/hashicorp/consul/api"
@@ -123,6 +127,9 @@ func TestAccConsulACLToken_import(t *testing.T) {
 		if !ok || v != "true" {
 			return fmt.Errorf("bad local: %s", s)
 		}
+		if v := s[0].Attributes["secret_id"]; v != "" {
+			return fmt.Errorf("the secret ID should not be imported: %s", s)
+		}
 
 		return nil
 	}
@@ -142,6 +149,135 @@ func TestAccConsulACLToken_import(t *testing.T) {
 	})
 }
 
+func TestAccConsulACLToken_secretID(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	checkSecretID := func(secretID string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			rs, ok := s.RootModule().Resources["consul_acl_token.test"]
+			if !ok {
+				return fmt.Errorf("consul_acl_token.test not found")
+			}
+			token, _, err := client.ACL().TokenRead(rs.Primary.ID, nil)
+			if err != nil {
+				return err
+			}
+			if token.SecretID != secretID {
+				return fmt.Errorf("wrong secret ID, expected %q, got %q", secretID, token.SecretID)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		Providers:    providers,
+		CheckDestroy: testAccCheckConsulACLTokenDestroy(client),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testResourceACLTokenConfigSecretID, "9a1c2e3f-4b5d-4e6f-8a7b-0c1d2e3f4a5b", false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("consul_acl_token.test", "secret_id", "9a1c2e3f-4b5d-4e6f-8a7b-0c1d2e3f4a5b"),
+					checkSecretID("9a1c2e3f-4b5d-4e6f-8a7b-0c1d2e3f4a5b"),
+				),
+			},
+			{
+				// Changing the secret ID must replace the token even when it
+				// is not stored
+				Config: fmt.Sprintf(testResourceACLTokenConfigSecretID, "b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a", false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("consul_acl_token.test", "secret_id", "b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a"),
+					checkSecretID("b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a"),
+				),
+			},
+			{
+				Config:   fmt.Sprintf(testResourceACLTokenConfigSecretID, "b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a", false),
+				PlanOnly: true,
+			},
+			{
+				Config: fmt.Sprintf(testResourceACLTokenConfigSecretID, "b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a", true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("consul_acl_token.test", "secret_id", "b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a"),
+					checkSecretID("b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(testResourceACLTokenConfigSecretID, "0e5d1f3c-8a4b-4c2e-a6f7-1b9d8e7c6a5f", true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("consul_acl_token.test", "secret_id", "0e5d1f3c-8a4b-4c2e-a6f7-1b9d8e7c6a5f"),
+					checkSecretID("0e5d1f3c-8a4b-4c2e-a6f7-1b9d8e7c6a5f"),
+				),
+			},
+			{
+				Config: testResourceACLTokenConfigStoreSecretID,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccCheckTokenExistsAndValidUUID("consul_acl_token.test", "secret_id"),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceConsulACLToken_secretIDDiff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/acl/token/0d0f3a52-8f7c-4f3b-9b1e-2c6d7e8f9a0b" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"AccessorID": "0d0f3a52-8f7c-4f3b-9b1e-2c6d7e8f9a0b",
+			"SecretID":   "b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a",
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	config := &Config{Address: strings.TrimPrefix(server.URL, "http://")}
+	client, err := config.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config.client = client
+
+	// The token was imported or created without secret_id
+	state := &terraform.InstanceState{
+		ID: "0d0f3a52-8f7c-4f3b-9b1e-2c6d7e8f9a0b",
+		Attributes: map[string]string{
+			"id":                       "0d0f3a52-8f7c-4f3b-9b1e-2c6d7e8f9a0b",
+			"accessor_id":              "0d0f3a52-8f7c-4f3b-9b1e-2c6d7e8f9a0b",
+			"secret_id":                "",
+			"store_secret_id_in_state": "false",
+		},
+	}
+
+	testCases := map[string]struct {
+		secretID    string
+		requiresNew bool
+	}{
+		"same-secret-id": {
+			secretID:    "b4fc7a02-5f7d-4e0f-9d7d-3c4a2b6c9e1a",
+			requiresNew: false,
+		},
+		"different-secret-id": {
+			secretID:    "0e5d1f3c-8a4b-4c2e-a6f7-1b9d8e7c6a5f",
+			requiresNew: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"secret_id": tc.secretID,
+			}
+			diff, err := resourceConsulACLToken().Diff(state, terraform.NewResourceConfigRaw(raw), config)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if got := diff != nil && diff.RequiresNew(); got != tc.requiresNew {
+				t.Fatalf("expected requires new to be %t, got %t: %v", tc.requiresNew, got, diff)
+			}
+		})
+	}
+}
+
 func TestAccConsulACLToken_namespaceCE(t *testing.T) {
 	providers, _ := startTestServer(t)
 
@@ -172,6 +308,19 @@ func TestAccConsulACLToken_namespaceEE(t *testing.T) {
 }
 
 const (
+	testResourceACLTokenConfigSecretID = `
+resource "consul_acl_token" "test" {
+	description              = "test"
+	secret_id                = "%s"
+	store_secret_id_in_state = %t
+}`
+
+	testResourceACLTokenConfigStoreSecretID = `
+resource "consul_acl_token" "test" {
+	description              = "generated"
+	store_secret_id_in_state = true
+}`
+
 	testResourceACLTokenConfigBasic = `
 resource "consul_acl_policy" "test" {
 	name = "test-token-basic"
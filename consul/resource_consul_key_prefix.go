@@ -4,9 +4,15 @@
 package consul
 
 import (
+	"encoding/base64"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
 
+	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 )
 
 func resourceConsulKeyPrefix() *schema.Resource {
@@ -24,6 +30,22 @@ func resourceConsulKeyPrefix() *schema.Resource {
 			},
 		},
 
+		CustomizeDiff: func(d *schema.ResourceDiff, _ interface{}) error {
+			err := validateKeyBlocks(d, "subkey", func(map[string]interface{}) bool {
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			if err := validateSubkeys(d); err != nil {
+				return err
+			}
+			if d.Get("cas").(bool) && (d.HasChange("subkeys") || d.HasChange("subkey") || d.HasChange("cas")) {
+				d.SetNewComputed("modify_index")
+			}
+			return nil
+		},
+
 		Schema: map[string]*schema.Schema{
 			"datacenter": {
 				Type:     schema.TypeString,
@@ -46,13 +68,20 @@ func resourceConsulKeyPrefix() *schema.Resource {
 			},
 
 			"subkeys": {
-				Type:     schema.TypeMap,
-				Optional: true,
+				Type:             schema.TypeMap,
+				Optional:         true,
+				DiffSuppressFunc: diffKeyValue,
 				Elem: &schema.Schema{
 					Type: schema.TypeString,
 				},
 			},
 
+			"subkeys_format": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice(keyValueFormats, false),
+			},
+
 			"subkey": {
 				Type:     schema.TypeSet,
 				Optional: true,
@@ -65,7 +94,19 @@ func resourceConsulKeyPrefix() *schema.Resource {
 
 						"value": {
 							Type:     schema.TypeString,
-							Required: true,
+							Optional: true,
+						},
+
+						"value_base64": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringIsBase64,
+						},
+
+						"value_format": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringInSlice(keyValueFormats, false),
 						},
 
 						"flags": {
@@ -77,6 +118,39 @@ func resourceConsulKeyPrefix() *schema.Resource {
 				},
 			},
 
+			"cas": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+
+			"modify_index": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+
+			"disable_transactions": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+
+			"ownership": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      keyPrefixOwnershipExclusive,
+				ValidateFunc: validation.StringInSlice([]string{keyPrefixOwnershipExclusive, keyPrefixOwnershipManagedKeysOnly}, false),
+			},
+
+			"unmanaged_keys": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
 			"namespace": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -91,6 +165,16 @@ func resourceConsulKeyPrefix() *schema.Resource {
 	}
 }
 
+const (
+	// keyPrefixOwnershipExclusive means that all the keys under the prefix
+	// are managed by Terraform, the unknown keys are deleted.
+	keyPrefixOwnershipExclusive = "exclusive"
+
+	// keyPrefixOwnershipManagedKeysOnly means that Terraform only manages the
+	// keys it wrote, the other keys under the prefix are left untouched.
+	keyPrefixOwnershipManagedKeysOnly = "managed_keys_only"
+)
+
 func resourceConsulKeyPrefixCreate(d *schema.ResourceData, meta interface{}) error {
 	keyClient := newKeyClient(d, meta)
 
@@ -101,7 +185,11 @@ func resourceConsulKeyPrefixCreate(d *schema.ResourceData, meta interface{}) err
 
 	pathPrefix := d.Get("path_prefix").(string)
 	subKeys := map[string]subKey{}
+	format := d.Get("subkeys_format").(string)
 	for k, vI := range d.Get("subkeys").(map[string]interface{}) {
+		if err := validateKeyValue(format, k, vI.(string)); err != nil {
+			return err
+		}
 		subKeys[k] = subKey{value: vI.(string), flags: 0}
 	}
 
@@ -111,7 +199,13 @@ func resourceConsulKeyPrefixCreate(d *schema.ResourceData, meta interface{}) err
 		for _, rawSubkey := range subkeysList {
 			subkeyData := rawSubkey.(map[string]interface{})
 			name := subkeyData["path"].(string)
-			value := subkeyData["value"].(string)
+			value, err := keyValue(subkeyData)
+			if err != nil {
+				return err
+			}
+			if err := validateKeyValue(subkeyData["value_format"].(string), name, value); err != nil {
+				return err
+			}
 			flags := subkeyData["flags"].(int)
 
 			subKeys[name] = subKey{
@@ -123,16 +217,27 @@ func resourceConsulKeyPrefixCreate(d *schema.ResourceData, meta interface{}) err
 
 	// To reduce the impact of mistakes, we will only "create" a prefix that
 	// is currently empty. This way we are less likely to accidentally
-	// conflict with other mechanisms managing the same prefix.
+	// conflict with other mechanisms managing the same prefix. This does not
+	// apply when the prefix is shared with other writers.
 	currentKVPairs, err := keyClient.GetUnderPrefix(pathPrefix)
 	if err != nil {
 		return err
 	}
-	if len(currentKVPairs) > 0 {
-		return fmt.Errorf(
-			"%d keys already exist under %s; delete them before managing this prefix with Terraform",
-			len(currentKVPairs), pathPrefix,
-		)
+	if d.Get("ownership").(string) == keyPrefixOwnershipExclusive {
+		if len(currentKVPairs) > 0 {
+			return fmt.Errorf(
+				"%d keys already exist under %s; delete them before managing this prefix with Terraform",
+				len(currentKVPairs), pathPrefix,
+			)
+		}
+	} else {
+		names := map[string]bool{}
+		for name := range subKeys {
+			names[name] = true
+		}
+		if err := checkKeyPrefixConflicts(currentKVPairs, pathPrefix, names); err != nil {
+			return err
+		}
 	}
 
 	// Ideally we'd use d.Partial(true) here so we can correctly record
@@ -155,15 +260,23 @@ func resourceConsulKeyPrefixCreate(d *schema.ResourceData, meta interface{}) err
 	// that nothing should need deleting yet, as long as there isn't some
 	// other program racing us to write values... which we'll catch on a
 	// subsequent Read.
+	batch := &keyBatch{}
+	if keyCASEnabled(d) {
+		batch.indexes = map[string]uint64{}
+	}
 	for name, subkey := range subKeys {
 		fullPath := pathPrefix + name
-		err := keyClient.Put(fullPath, subkey.value, subkey.flags)
-		if err != nil {
-			return fmt.Errorf("error while writing %s: %s", fullPath, err)
+		if batch.indexes != nil {
+			batch.indexes[fullPath] = 0
 		}
+		batch.Put(fullPath, subkey.value, subkey.flags)
 	}
 
-	return nil
+	if err := keyClient.Apply(batch, !d.Get("disable_transactions").(bool)); err != nil {
+		return err
+	}
+
+	return resourceConsulKeyPrefixRead(d, meta)
 }
 
 func resourceConsulKeyPrefixUpdate(d *schema.ResourceData, meta interface{}) error {
@@ -171,6 +284,50 @@ func resourceConsulKeyPrefixUpdate(d *schema.ResourceData, meta interface{}) err
 
 	pathPrefix := d.Get("path_prefix").(string)
 
+	// All the changes are collected in a single batch so that they can be
+	// applied atomically, consumers of the prefix never see a half-updated
+	// configuration.
+	batch := &keyBatch{}
+
+	// With check-and-set enabled, the subkeys are only written if they were
+	// not modified since they were last read and the new subkeys must not
+	// exist yet.
+	if keyCASEnabled(d) {
+		oldIndexes, _ := d.GetChange("modify_index")
+		batch.indexes = keyIndexes(oldIndexes, pathPrefix)
+
+		for name := range keyPrefixManagedKeys(d) {
+			if _, ok := batch.indexes[pathPrefix+name]; !ok {
+				batch.indexes[pathPrefix+name] = 0
+			}
+		}
+	}
+
+	// The keys Terraform starts managing must not overwrite the ones of the
+	// other writers of the prefix
+	if d.Get("ownership").(string) == keyPrefixOwnershipManagedKeysOnly {
+		oldSubkeys, _ := d.GetChange("subkeys")
+		oldSubkey, _ := d.GetChange("subkey")
+		previous := keyPrefixNames(oldSubkeys, oldSubkey)
+
+		added := map[string]bool{}
+		for name := range keyPrefixManagedKeys(d) {
+			if !previous[name] {
+				added[name] = true
+			}
+		}
+
+		if len(added) > 0 {
+			currentKVPairs, err := keyClient.GetUnderPrefix(pathPrefix)
+			if err != nil {
+				return err
+			}
+			if err := checkKeyPrefixConflicts(currentKVPairs, pathPrefix, added); err != nil {
+				return err
+			}
+		}
+	}
+
 	if d.HasChange("subkeys") {
 		o, n := d.GetChange("subkeys")
 		if o == nil {
@@ -185,22 +342,18 @@ func resourceConsulKeyPrefixUpdate(d *schema.ResourceData, meta interface{}) err
 
 		// First we'll write all of the stuff in the "new map" nm,
 		// and then we'll delete any keys that appear in the "old map" om
-		// and do not also appear in nm. This ordering means that if a subkey
-		// name is changed we will briefly have both the old and new names in
-		// Consul, as opposed to briefly having neither.
-
-		// Again, we'd ideally use d.Partial(true) here but it doesn't work
-		// for maps and so we'll just rely on a subsequent Read to tidy up
-		// after a partial write.
+		// and do not also appear in nm. When transactions are disabled,
+		// this ordering means that if a subkey name is changed we will
+		// briefly have both the old and new names in Consul, as opposed to
+		// briefly having neither.
 
 		// Write new and changed keys
+		format := d.Get("subkeys_format").(string)
 		for k, vI := range nm {
-			v := vI.(string)
-			fullPath := pathPrefix + k
-			err := keyClient.Put(fullPath, v, 0)
-			if err != nil {
-				return fmt.Errorf("error while writing %s: %s", fullPath, err)
+			if err := validateKeyValue(format, k, vI.(string)); err != nil {
+				return err
 			}
+			batch.Put(pathPrefix+k, vI.(string), 0)
 		}
 
 		// Remove deleted keys
@@ -208,11 +361,7 @@ func resourceConsulKeyPrefixUpdate(d *schema.ResourceData, meta interface{}) err
 			if _, exists := nm[k]; exists {
 				continue
 			}
-			fullPath := pathPrefix + k
-			err := keyClient.Delete(fullPath)
-			if err != nil {
-				return fmt.Errorf("error while deleting %s: %s", fullPath, err)
-			}
+			batch.Delete(pathPrefix + k)
 		}
 	}
 
@@ -240,34 +389,38 @@ func resourceConsulKeyPrefixUpdate(d *schema.ResourceData, meta interface{}) err
 			key := rawSubkey.(map[string]interface{})
 
 			name := key["path"].(string)
-			value := key["value"].(string)
+			value, err := keyValue(key)
+			if err != nil {
+				return err
+			}
+			if err := validateKeyValue(key["value_format"].(string), name, value); err != nil {
+				return err
+			}
 			flags := key["flags"].(int)
 
 			// Delete from old keys (if exists) so it will not be removed in last step
 			delete(oldSubKeys, name)
 
-			fullPath := pathPrefix + name
-			err := keyClient.Put(fullPath, value, flags)
-			if err != nil {
-				return fmt.Errorf("error while writing %s: %s", fullPath, err)
-			}
+			batch.Put(pathPrefix+name, value, flags)
 		}
 
 		// Remove remaining old subkey
 		for path := range oldSubKeys {
-			fullPath := pathPrefix + path
-			err := keyClient.Delete(fullPath)
-			if err != nil {
-				return fmt.Errorf("error while deleting %s: %s", fullPath, err)
-			}
+			batch.Delete(pathPrefix + path)
 		}
 	}
 
+	// If the batch only partially succeeds because it had to be split or
+	// transactions are disabled, a subsequent Read will tidy up.
+	if err := keyClient.Apply(batch, !d.Get("disable_transactions").(bool)); err != nil {
+		return err
+	}
+
 	// Store the datacenter on this resource, which can be helpful for reference
 	// in case it was read from the provider
 	d.Set("datacenter", keyClient.qOpts.Datacenter)
 
-	return nil
+	return resourceConsulKeyPrefixRead(d, meta)
 }
 
 func resourceConsulKeyPrefixRead(d *schema.ResourceData, meta interface{}) error {
@@ -282,6 +435,21 @@ func resourceConsulKeyPrefixRead(d *schema.ResourceData, meta interface{}) error
 
 	subKeys := make(map[string]string)
 	subKeySet := make([]interface{}, 0)
+	indexes := make(map[string]int)
+	cas := d.Get("cas").(bool)
+
+	// When the values are documents written with another formatting, the
+	// values previously written are kept
+	format := d.Get("subkeys_format").(string)
+	currentSubKeys := d.Get("subkeys").(map[string]interface{})
+
+	// When the prefix is shared, only the keys written by Terraform are
+	// tracked and the other ones are ignored.
+	var managed map[string]bool
+	unknown := []string{}
+	if d.Get("ownership").(string) == keyPrefixOwnershipManagedKeysOnly {
+		managed = keyPrefixManagedKeys(d)
+	}
 
 	// We need to split subkeys fetched between the subkey and subkeys attributes:
 	//   - everything whose path matches a given subkey in subkeyList goes in subkeySet
@@ -293,14 +461,31 @@ func resourceConsulKeyPrefixRead(d *schema.ResourceData, meta interface{}) error
 		flags := int(pair.Flags)
 		isSubkey := false
 
+		if managed != nil && !managed[name] {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		if cas {
+			indexes[name] = int(pair.ModifyIndex)
+		}
+
 		for _, rawSubkey := range subkeyList {
 			subkeyData := rawSubkey.(map[string]interface{})
 			if name == subkeyData["path"] {
 				isSubkey = true
+				format := subkeyData["value_format"].(string)
 				subkey := map[string]interface{}{
-					"path":  name,
-					"value": value,
-					"flags": flags,
+					"path":         name,
+					"value":        normalizeKeyValue(format, subkeyData["value"].(string), value),
+					"flags":        flags,
+					"value_format": format,
+				}
+				if subkeyData["value_base64"].(string) != "" {
+					// The value is binary, it is only stored encoded so
+					// that it is not mangled by Terraform
+					subkey["value"] = ""
+					subkey["value_base64"] = base64.StdEncoding.EncodeToString(pair.Value)
 				}
 				subKeySet = append(subKeySet, subkey)
 				break
@@ -308,14 +493,25 @@ func resourceConsulKeyPrefixRead(d *schema.ResourceData, meta interface{}) error
 		}
 
 		if !isSubkey {
-			subKeys[name] = string(value)
+			current, _ := currentSubKeys[name].(string)
+			subKeys[name] = normalizeKeyValue(format, current, value)
 		}
 	}
 
+	sort.Strings(unknown)
+	if len(unknown) > 0 {
+		log.Printf(
+			"[WARN] %d keys under '%s' are not managed by Terraform and are ignored: %s",
+			len(unknown), pathPrefix, strings.Join(unknown, ", "),
+		)
+	}
+
 	sw := newStateWriter(d)
 
 	sw.set("subkey", subKeySet)
 	sw.set("subkeys", subKeys)
+	sw.set("modify_index", indexes)
+	sw.set("unmanaged_keys", unknown)
 
 	// Store the datacenter on this resource, which can be helpful for reference
 	// in case it was read from the provider
@@ -329,6 +525,35 @@ func resourceConsulKeyPrefixDelete(d *schema.ResourceData, meta interface{}) err
 
 	pathPrefix := d.Get("path_prefix").(string)
 
+	// With check-and-set enabled, the subkeys are first deleted only if they
+	// were not modified since they were last read so that a conflict aborts
+	// the deletion.
+	if d.Get("cas").(bool) {
+		indexes := keyIndexes(d.Get("modify_index"), pathPrefix)
+		batch := &keyBatch{indexes: indexes}
+		for path := range indexes {
+			batch.Delete(path)
+		}
+		if err := keyClient.Apply(batch, !d.Get("disable_transactions").(bool)); err != nil {
+			return err
+		}
+	}
+
+	// When the prefix is shared, only the keys written by Terraform are
+	// deleted.
+	if d.Get("ownership").(string) == keyPrefixOwnershipManagedKeysOnly {
+		batch := &keyBatch{}
+		for name := range keyPrefixManagedKeys(d) {
+			batch.Delete(pathPrefix + name)
+		}
+		if err := keyClient.Apply(batch, !d.Get("disable_transactions").(bool)); err != nil {
+			return err
+		}
+
+		d.SetId("")
+		return nil
+	}
+
 	// Delete everything under our prefix, since the entire set of keys under
 	// the given prefix is considered to be managed exclusively by Terraform.
 	err := keyClient.DeleteUnderPrefix(pathPrefix)
@@ -340,3 +565,48 @@ func resourceConsulKeyPrefixDelete(d *schema.ResourceData, meta interface{}) err
 
 	return nil
 }
+
+// keyPrefixManagedKeys returns the names, relative to the prefix, of the keys
+// written by Terraform.
+func keyPrefixManagedKeys(d *schema.ResourceData) map[string]bool {
+	return keyPrefixNames(d.Get("subkeys"), d.Get("subkey"))
+}
+
+// keyPrefixNames returns the names of the keys in the subkeys and subkey
+// attributes.
+func keyPrefixNames(subkeys, subkey interface{}) map[string]bool {
+	names := map[string]bool{}
+	if subkeys, ok := subkeys.(map[string]interface{}); ok {
+		for name := range subkeys {
+			names[name] = true
+		}
+	}
+	if subkey, ok := subkey.(*schema.Set); ok {
+		for _, raw := range subkey.List() {
+			names[raw.(map[string]interface{})["path"].(string)] = true
+		}
+	}
+	return names
+}
+
+// checkKeyPrefixConflicts returns an error when some of the keys Terraform
+// is about to write already exist under the prefix, since they belong to the
+// other writers of the prefix.
+func checkKeyPrefixConflicts(pairs consulapi.KVPairs, pathPrefix string, names map[string]bool) error {
+	var conflicts []string
+	for _, pair := range pairs {
+		name := pair.Key[len(pathPrefix):]
+		if names[name] {
+			conflicts = append(conflicts, name)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	sort.Strings(conflicts)
+	return fmt.Errorf(
+		"%d keys already exist under %s: %s; delete them before managing them with Terraform",
+		len(conflicts), pathPrefix, strings.Join(conflicts, ", "),
+	)
+}
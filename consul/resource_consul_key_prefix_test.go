@@ -4,12 +4,16 @@
 package consul
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"reflect"
 	"regexp"
 	"testing"
 
 	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
 
@@ -412,3 +416,150 @@ resource "consul_key_prefix" "root" {
 	}
 }
 `
+
+func TestResourceConsulKeyPrefix_valueBase64(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	// Not a valid UTF-8 string
+	binary := []byte{0x1f, 0x8b, 0x08, 0x00, 0xff, 0xc0, 0xaf}
+	encoded := base64.StdEncoding.EncodeToString(binary)
+
+	r := resourceConsulKeyPrefix()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"path_prefix": "prefix_test/",
+		"subkeys": map[string]interface{}{
+			"text": "hello",
+		},
+		"subkey": []interface{}{
+			map[string]interface{}{
+				"path":         "archive.gz",
+				"value_base64": encoded,
+				"flags":        2,
+			},
+		},
+	})
+	if err := resourceConsulKeyPrefixCreate(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	pair := kv.pairs["prefix_test/archive.gz"]
+	if pair == nil || !bytes.Equal(pair.Value, binary) || pair.Flags != 2 {
+		t.Fatalf("expected the value to be written byte-exact, got %#v", pair)
+	}
+
+	subkeys := d.Get("subkey").(*schema.Set).List()
+	if len(subkeys) != 1 {
+		t.Fatalf("expected 1 subkey, got %d", len(subkeys))
+	}
+	subkey := subkeys[0].(map[string]interface{})
+	if subkey["value_base64"] != encoded || subkey["value"] != "" {
+		t.Fatalf("unexpected subkey %#v", subkey)
+	}
+	if v := d.Get("subkeys.text"); v != "hello" {
+		t.Fatalf("expected subkeys.text to be hello, got %q", v)
+	}
+}
+
+func TestResourceConsulKeyPrefix_ownership(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	// A key written by an application sharing the prefix
+	kv.set("prefix_test/runtime/leader", []byte("node-1"), 0)
+
+	r := resourceConsulKeyPrefix()
+	raw := map[string]interface{}{
+		"path_prefix": "prefix_test/",
+		"subkeys": map[string]interface{}{
+			"name": "web",
+		},
+		"subkey": []interface{}{
+			map[string]interface{}{
+				"path":  "port",
+				"value": "8080",
+			},
+		},
+	}
+
+	// The prefix is not empty
+	d := schema.TestResourceDataRaw(t, r.Schema, raw)
+	err := resourceConsulKeyPrefixCreate(d, config)
+	if err == nil || !regexp.MustCompile("1 keys already exist under prefix_test/").MatchString(err.Error()) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The keys written by Terraform must not exist yet
+	raw["ownership"] = "managed_keys_only"
+	kv.set("prefix_test/port", []byte("80"), 0)
+	d = schema.TestResourceDataRaw(t, r.Schema, raw)
+	err = resourceConsulKeyPrefixCreate(d, config)
+	if err == nil || !regexp.MustCompile("1 keys already exist under prefix_test/: port;").MatchString(err.Error()) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	delete(kv.pairs, "prefix_test/port")
+	d = schema.TestResourceDataRaw(t, r.Schema, raw)
+	if err := resourceConsulKeyPrefixCreate(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The unknown keys are reported
+	if got := d.Get("unmanaged_keys"); !reflect.DeepEqual(got, []interface{}{"runtime/leader"}) {
+		t.Fatalf("expected the unknown key to be reported, got %v", got)
+	}
+
+	// The unknown key is not tracked
+	expected := map[string]interface{}{"name": "web"}
+	if got := d.Get("subkeys"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := d.Get("subkey").(*schema.Set).Len(); got != 1 {
+		t.Fatalf("expected 1 subkey, got %d", got)
+	}
+
+	// Adding a key that belongs to another writer must not overwrite it
+	state := d.State()
+	raw["subkeys"] = map[string]interface{}{
+		"name":           "web",
+		"runtime/leader": "node-2",
+	}
+	diff, err := r.Diff(state, terraform.NewResourceConfigRaw(raw), config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err = r.Apply(state, diff, config)
+	if err == nil || !regexp.MustCompile("1 keys already exist under prefix_test/: runtime/leader;").MatchString(err.Error()) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(kv.pairs["prefix_test/runtime/leader"].Value); got != "node-1" {
+		t.Fatalf("expected the unknown key to be kept, got %q", got)
+	}
+
+	// The keys already managed can still be updated
+	raw["subkeys"] = map[string]interface{}{
+		"name":  "api",
+		"other": "value",
+	}
+	diff, err = r.Diff(state, terraform.NewResourceConfigRaw(raw), config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := r.Apply(state, diff, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := string(kv.pairs["prefix_test/name"].Value); got != "api" {
+		t.Fatalf("expected the managed key to be updated, got %q", got)
+	}
+
+	// Only the managed keys are deleted
+	if err := resourceConsulKeyPrefixDelete(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, key := range []string{"prefix_test/name", "prefix_test/port"} {
+		if _, ok := kv.pairs[key]; ok {
+			t.Fatalf("expected %s to be deleted", key)
+		}
+	}
+	if _, ok := kv.pairs["prefix_test/runtime/leader"]; !ok {
+		t.Fatalf("expected the unknown key to be kept")
+	}
+}
@@ -4,10 +4,14 @@
 package consul
 
 import (
+	"encoding/base64"
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 )
 
 func resourceConsulKeys() *schema.Resource {
@@ -16,14 +20,28 @@ func resourceConsulKeys() *schema.Resource {
 		Update: resourceConsulKeysCreateUpdate,
 		Read:   resourceConsulKeysRead,
 		Delete: resourceConsulKeysDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceConsulKeysImport,
+		},
 
 		SchemaVersion: 1,
 		MigrateState:  resourceConsulKeysMigrateState,
 
 		CustomizeDiff: func(d *schema.ResourceDiff, _ interface{}) error {
+			// Only the keys written by Terraform need a value, the others
+			// are read
+			err := validateKeyBlocks(d, "key", func(sub map[string]interface{}) bool {
+				return sub["name"].(string) == ""
+			})
+			if err != nil {
+				return err
+			}
 			if d.HasChange("key") {
 				d.SetNewComputed("var")
 			}
+			if d.Get("cas").(bool) && (d.HasChange("key") || d.HasChange("cas")) {
+				d.SetNewComputed("modify_index")
+			}
 			return nil
 		},
 
@@ -65,6 +83,18 @@ func resourceConsulKeys() *schema.Resource {
 							Computed: true,
 						},
 
+						"value_base64": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringIsBase64,
+						},
+
+						"value_format": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringInSlice(keyValueFormats, false),
+						},
+
 						"flags": {
 							Type:     schema.TypeInt,
 							Optional: true,
@@ -94,6 +124,24 @@ func resourceConsulKeys() *schema.Resource {
 				},
 			},
 
+			"cas": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+
+			"modify_index": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+
+			"disable_transactions": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+
 			"namespace": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -133,11 +181,31 @@ func resourceConsulKeysCreateUpdate(d *schema.ResourceData, meta interface{}) er
 		// value and then immediately removing it.
 		addedPaths := make(map[string]bool)
 
-		// We add before we remove because then it's possible to change
-		// a key name (which will result in both an add and a remove)
-		// without very temporarily having *neither* value in the store.
-		// Instead, both will briefly be present, which should be less
-		// disruptive in most cases.
+		// All the changes are applied in a single transaction when possible.
+		// When transactions are disabled, we add before we remove because
+		// then it's possible to change a key name (which will result in both
+		// an add and a remove) without very temporarily having *neither*
+		// value in the store. Instead, both will briefly be present, which
+		// should be less disruptive in most cases.
+		batch := &keyBatch{}
+
+		// With check-and-set enabled, the keys are only written if they were
+		// not modified since they were last read and the new keys must not
+		// exist yet.
+		if keyCASEnabled(d) {
+			oldIndexes, _ := d.GetChange("modify_index")
+			batch.indexes = keyIndexes(oldIndexes, "")
+			for _, raw := range add {
+				_, path, _, err := parseKey(raw)
+				if err != nil {
+					return err
+				}
+				if _, ok := batch.indexes[path]; !ok {
+					batch.indexes[path] = 0
+				}
+			}
+		}
+
 		for _, raw := range add {
 			_, path, sub, err := parseKey(raw)
 			if err != nil {
@@ -148,16 +216,20 @@ func resourceConsulKeysCreateUpdate(d *schema.ResourceData, meta interface{}) er
 			// from the KV store. We must not overwrite the value when are
 			// reading.
 			name := sub["name"].(string)
-			value := sub["value"].(string)
+			value, err := keyValue(sub)
+			if err != nil {
+				return err
+			}
 			if name != "" && value == "" {
 				continue
 			}
+			if err := validateKeyValue(sub["value_format"].(string), path, value); err != nil {
+				return err
+			}
 
 			flags := sub["flags"].(int)
 
-			if err := keyClient.Put(path, value, flags); err != nil {
-				return err
-			}
+			batch.Put(path, value, flags)
 			addedPaths[path] = true
 		}
 
@@ -178,9 +250,11 @@ func resourceConsulKeysCreateUpdate(d *schema.ResourceData, meta interface{}) er
 				continue
 			}
 
-			if err := keyClient.Delete(path); err != nil {
-				return err
-			}
+			batch.Delete(path)
+		}
+
+		if err := keyClient.Apply(batch, !d.Get("disable_transactions").(bool)); err != nil {
+			return err
 		}
 	}
 
@@ -200,6 +274,8 @@ func resourceConsulKeysRead(d *schema.ResourceData, meta interface{}) error {
 	keyClient := newKeyClient(d, meta)
 
 	vars := make(map[string]string)
+	indexes := make(map[string]int)
+	cas := d.Get("cas").(bool)
 
 	keys := d.Get("key").(*schema.Set).List()
 	for _, raw := range keys {
@@ -208,12 +284,33 @@ func resourceConsulKeysRead(d *schema.ResourceData, meta interface{}) error {
 			return err
 		}
 
-		_, value, flags, err := keyClient.Get(path)
+		pair, err := keyClient.GetPair(path)
 		if err != nil {
 			return err
 		}
+		var value, encoded string
+		var flags, index int
+		if pair != nil {
+			value = string(pair.Value)
+			encoded = base64.StdEncoding.EncodeToString(pair.Value)
+			flags = int(pair.Flags)
+			index = int(pair.ModifyIndex)
+		}
 		sub["flags"] = flags
 
+		// The index of the keys that are not written by Terraform is not
+		// needed
+		if cas && name == "" {
+			indexes[path] = index
+		}
+
+		if name == "" && sub["value_base64"].(string) != "" {
+			// The value is binary, it is only stored encoded so that it
+			// is not mangled by Terraform
+			sub["value_base64"] = encoded
+			continue
+		}
+
 		value = attributeValue(sub, value)
 		if name != "" {
 			// If 'name' is set then we'll update vars, for backward-compatibilty
@@ -228,7 +325,9 @@ func resourceConsulKeysRead(d *schema.ResourceData, meta interface{}) error {
 			// written by Terraform.
 			// We don't do this for "read" blocks; that causes confusing diffs
 			// because "value" should not be set for read-only key blocks.
-			sub["value"] = value
+			// When the value is a document written with another formatting,
+			// the value previously written is kept.
+			sub["value"] = normalizeKeyValue(sub["value_format"].(string), sub["value"].(string), value)
 		}
 	}
 
@@ -238,6 +337,9 @@ func resourceConsulKeysRead(d *schema.ResourceData, meta interface{}) error {
 	if err := d.Set("key", keys); err != nil {
 		return err
 	}
+	if err := d.Set("modify_index", indexes); err != nil {
+		return err
+	}
 
 	// Store the datacenter on this resource, which can be helpful for reference
 	// in case it was read from the provider
@@ -249,6 +351,11 @@ func resourceConsulKeysRead(d *schema.ResourceData, meta interface{}) error {
 func resourceConsulKeysDelete(d *schema.ResourceData, meta interface{}) error {
 	keyClient := newKeyClient(d, meta)
 
+	batch := &keyBatch{}
+	if d.Get("cas").(bool) {
+		batch.indexes = keyIndexes(d.Get("modify_index"), "")
+	}
+
 	// Clean up any keys that we're explicitly managing
 	keys := d.Get("key").(*schema.Set).List()
 	for _, raw := range keys {
@@ -263,9 +370,11 @@ func resourceConsulKeysDelete(d *schema.ResourceData, meta interface{}) error {
 			continue
 		}
 
-		if err := keyClient.Delete(path); err != nil {
-			return err
-		}
+		batch.Delete(path)
+	}
+
+	if err := keyClient.Apply(batch, !d.Get("disable_transactions").(bool)); err != nil {
+		return err
 	}
 
 	// Clear the ID
@@ -273,6 +382,79 @@ func resourceConsulKeysDelete(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
+// resourceConsulKeysImport imports the keys given in an ID of the form
+// "<datacenter>:<path1>,<path2>" or
+// "<partition>/<namespace>/<datacenter>:<path1>,<path2>", the datacenter,
+// partition and namespace being optional.
+func resourceConsulKeysImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	errInvalid := fmt.Errorf(`expected ID of the form "<datacenter>:<path1>,<path2>" or "<partition>/<namespace>/<datacenter>:<path1>,<path2>", got %q`, d.Id())
+
+	location, rawPaths, ok := strings.Cut(d.Id(), ":")
+	if !ok || rawPaths == "" {
+		return nil, errInvalid
+	}
+
+	var datacenter, namespace, partition string
+	parts := strings.Split(location, "/")
+	switch len(parts) {
+	case 1:
+		datacenter = parts[0]
+	case 3:
+		partition = parts[0]
+		namespace = parts[1]
+		datacenter = parts[2]
+	default:
+		return nil, errInvalid
+	}
+
+	sw := newStateWriter(d)
+	sw.set("datacenter", datacenter)
+	sw.set("namespace", namespace)
+	sw.set("partition", partition)
+	if err := sw.error(); err != nil {
+		return nil, err
+	}
+
+	keyClient := newKeyClient(d, meta)
+
+	keys := []interface{}{}
+	for _, path := range strings.Split(rawPaths, ",") {
+		if path == "" {
+			return nil, errInvalid
+		}
+
+		pair, err := keyClient.GetPair(path)
+		if err != nil {
+			return nil, err
+		}
+		if pair == nil {
+			return nil, fmt.Errorf("failed to import Consul key '%s': the key does not exist", path)
+		}
+
+		key := map[string]interface{}{
+			"path":  path,
+			"flags": int(pair.Flags),
+		}
+		// Binary values are imported encoded so that they are not mangled
+		// by Terraform
+		if utf8.Valid(pair.Value) {
+			key["value"] = string(pair.Value)
+		} else {
+			key["value_base64"] = base64.StdEncoding.EncodeToString(pair.Value)
+		}
+		keys = append(keys, key)
+	}
+
+	sw.set("key", keys)
+	sw.set("datacenter", keyClient.qOpts.Datacenter)
+	if err := sw.error(); err != nil {
+		return nil, err
+	}
+
+	d.SetId("consul")
+	return []*schema.ResourceData{d}, nil
+}
+
 // parseKey is used to parse a key into a name, path, config or error
 func parseKey(raw interface{}) (string, string, map[string]interface{}, error) {
 	sub, ok := raw.(map[string]interface{})
@@ -289,6 +471,72 @@ func parseKey(raw interface{}) (string, string, map[string]interface{}, error) {
 	return key, path, sub, nil
 }
 
+// validateKeyBlocks checks at plan time that the blocks in attr do not set
+// both value and value_base64, that exactly one of them is set for the keys
+// written by Terraform, as reported by written, and that the values are valid
+// documents in their value_format.
+func validateKeyBlocks(d *schema.ResourceDiff, attr string, written func(map[string]interface{}) bool) error {
+	set := d.Get(attr).(*schema.Set)
+	for _, raw := range set.List() {
+		// The blocks with unknown values are checked once they are known,
+		// they are stored under a hash prefixed with "~" until then
+		computed := fmt.Sprintf("%s.~%d", attr, set.F(raw))
+		if !d.NewValueKnown(computed+".value") || !d.NewValueKnown(computed+".value_base64") || !d.NewValueKnown(computed+".value_format") {
+			continue
+		}
+
+		sub := raw.(map[string]interface{})
+		if sub["value"].(string) != "" && sub["value_base64"].(string) != "" {
+			return fmt.Errorf("only one of value and value_base64 can be set for key %q", sub["path"])
+		}
+
+		value, err := keyValue(sub)
+		if err != nil {
+			return err
+		}
+		if !written(sub) {
+			// The keys that are only read are not written when they have
+			// no value
+			if value != "" {
+				if err := validateKeyValue(sub["value_format"].(string), sub["path"].(string), value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		hash := set.F(raw)
+		_, valueSet := d.GetOkExists(fmt.Sprintf("%s.%d.value", attr, hash))
+		_, encodedSet := d.GetOkExists(fmt.Sprintf("%s.%d.value_base64", attr, hash))
+		if !valueSet && !encodedSet {
+			return fmt.Errorf("one of value or value_base64 must be set for key %q", sub["path"])
+		}
+		if err := validateKeyValue(sub["value_format"].(string), sub["path"].(string), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyValue returns the value to write for a key, decoding value_base64 when
+// it is set.
+func keyValue(sub map[string]interface{}) (string, error) {
+	value := sub["value"].(string)
+	encoded, _ := sub["value_base64"].(string)
+	if encoded == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("only one of value and value_base64 can be set for key %q", sub["path"])
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode value_base64 of key %q: %v", sub["path"], err)
+	}
+	return string(decoded), nil
+}
+
 // attributeValue determines the value for a key, potentially
 // using a default value if provided.
 func attributeValue(sub map[string]interface{}, readValue string) string {
@@ -4,11 +4,16 @@
 package consul
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 
 	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
 
@@ -26,7 +31,10 @@ func TestAccConsulKeys_basic(t *testing.T) {
 					testAccCheckConsulKeysValue("consul_keys.app", "enabled", "true"),
 					testAccCheckConsulKeysValue("consul_keys.app", "set", "acceptance"),
 					testAccCheckConsulKeysValue("consul_keys.app", "remove_one", "hello"),
-					resource.TestCheckResourceAttr("consul_keys.app", "key.4258512057.flags", "0"),
+					testAccCheckTypeSetElemNestedAttrs("consul_keys.app", "key", map[string]string{
+						"name":  "remove_one",
+						"flags": "0",
+					}),
 				),
 			},
 			{
@@ -42,6 +50,52 @@ func TestAccConsulKeys_basic(t *testing.T) {
 	})
 }
 
+func TestAccConsulKeys_cas(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers:    providers,
+		CheckDestroy: testAccCheckConsulKeysDestroy(client),
+		Steps: []resource.TestStep{
+			{
+				// The key must not exist when it is created with cas
+				PreConfig: func() {
+					_, err := client.KV().Put(&consulapi.KVPair{Key: "test/cas", Value: []byte("rogue")}, nil)
+					if err != nil {
+						t.Fatalf("err: %s", err)
+					}
+				},
+				Config:      fmt.Sprintf(testAccConsulKeysConfig_cas, "foo"),
+				ExpectError: regexp.MustCompile("conflict on Consul key 'test/cas': expected ModifyIndex 0 but found [0-9]+"),
+			},
+			{
+				PreConfig: testAccDeleteConsulKey(t, client, "test/cas"),
+				Config:    fmt.Sprintf(testAccConsulKeysConfig_cas, "foo"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("consul_keys.app", "modify_index.%", "1"),
+					resource.TestCheckResourceAttrSet("consul_keys.app", "modify_index.test/cas"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(testAccConsulKeysConfig_cas, "bar"),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						pair, _, err := client.KV().Get("test/cas", nil)
+						if err != nil {
+							return err
+						}
+						if pair == nil || string(pair.Value) != "bar" {
+							return fmt.Errorf("expected test/cas to be updated, got %#v", pair)
+						}
+						return nil
+					},
+					resource.TestCheckResourceAttr("consul_keys.app", "modify_index.%", "1"),
+				),
+			},
+		},
+	})
+}
+
 func TestAccConsulKeys_EmptyValue(t *testing.T) {
 	providers, client := startTestServer(t)
 
@@ -56,6 +110,32 @@ func TestAccConsulKeys_EmptyValue(t *testing.T) {
 	})
 }
 
+func TestAccConsulKeys_import(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers:    providers,
+		CheckDestroy: testAccCheckConsulKeysDestroy(client),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccConsulKeysConfig_import,
+			},
+			{
+				ResourceName:      "consul_keys.app",
+				ImportState:       true,
+				ImportStateId:     "dc1:test/import/name,test/import/port",
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:  "consul_keys.app",
+				ImportState:   true,
+				ImportStateId: "dc1:test/import/unknown",
+				ExpectError:   regexp.MustCompile("failed to import Consul key 'test/import/unknown': the key does not exist"),
+			},
+		},
+	})
+}
+
 func TestAccConsulKeys_NamespaceCE(t *testing.T) {
 	providers, _ := startTestServer(t)
 
@@ -149,6 +229,48 @@ func testAccCheckConsulKeysValue(n, attr, val string) resource.TestCheckFunc {
 	}
 }
 
+// testAccCheckTypeSetElemNestedAttrs checks that one of the elements of the
+// set attr has the given values, without depending on the hash of the
+// elements.
+func testAccCheckTypeSetElemNestedAttrs(n, attr string, values map[string]string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rn, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Resource not found")
+		}
+
+		elems := map[string]map[string]string{}
+		prefix := attr + "."
+		for k, v := range rn.Primary.Attributes {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			parts := strings.SplitN(strings.TrimPrefix(k, prefix), ".", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			if elems[parts[0]] == nil {
+				elems[parts[0]] = map[string]string{}
+			}
+			elems[parts[0]][parts[1]] = v
+		}
+
+		for _, elem := range elems {
+			matches := true
+			for k, v := range values {
+				if elem[k] != v {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				return nil
+			}
+		}
+		return fmt.Errorf("no element of '%s' matches %v: %#v", attr, values, rn.Primary.Attributes)
+	}
+}
+
 func testAccCheckConsulKeysRemoved(n, attr string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rn, ok := s.RootModule().Resources[n]
@@ -232,6 +354,33 @@ resource "consul_keys" "app" {
 }
 `
 
+const testAccConsulKeysConfig_cas = `
+resource "consul_keys" "app" {
+	cas = true
+
+	key {
+		path   = "test/cas"
+		value  = "%s"
+		delete = true
+	}
+}
+`
+
+const testAccConsulKeysConfig_import = `
+resource "consul_keys" "app" {
+  key {
+    path  = "test/import/name"
+    value = "web"
+  }
+
+  key {
+    path  = "test/import/port"
+    value = "8080"
+    flags = 2
+  }
+}
+`
+
 const testAccConsulKeysEmptyValue = `
 resource "consul_keys" "consul" {
 	key {
@@ -286,3 +435,212 @@ resource "consul_keys" "dc2" {
 	}
 }
 `
+
+func TestResourceConsulKeys_valueBase64(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	// Not a valid UTF-8 string
+	binary := []byte{0xff, 0xfe, 0x00, 0x80, 'a', 0xc3}
+	encoded := base64.StdEncoding.EncodeToString(binary)
+
+	r := resourceConsulKeys()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"key": []interface{}{
+			map[string]interface{}{
+				"path":         "test/binary",
+				"value_base64": encoded,
+				"delete":       true,
+			},
+		},
+	})
+	if err := resourceConsulKeysCreateUpdate(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if pair := kv.pairs["test/binary"]; pair == nil || !bytes.Equal(pair.Value, binary) {
+		t.Fatalf("expected the value to be written byte-exact, got %#v", pair)
+	}
+
+	keys := d.Get("key").(*schema.Set).List()
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(keys))
+	}
+	key := keys[0].(map[string]interface{})
+	if key["value_base64"] != encoded {
+		t.Fatalf("expected value_base64 to be %q, got %q", encoded, key["value_base64"])
+	}
+	if key["value"] != "" {
+		t.Fatalf("expected value to be empty, got %q", key["value"])
+	}
+}
+
+func TestResourceConsulKeys_valueBase64Conflict(t *testing.T) {
+	_, config := newFakeKV(t)
+
+	r := resourceConsulKeys()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"key": []interface{}{
+			map[string]interface{}{
+				"path":         "test/binary",
+				"value":        "foo",
+				"value_base64": "Zm9v",
+			},
+		},
+	})
+	err := resourceConsulKeysCreateUpdate(d, config)
+	if err == nil || !strings.Contains(err.Error(), "only one of value and value_base64 can be set") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// unknownVariableValue is the value used by Terraform for the values that
+// are not known during the plan.
+const unknownVariableValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+func TestResourceConsulKeys_validateValues(t *testing.T) {
+	cases := map[string]struct {
+		resource *schema.Resource
+		config   map[string]interface{}
+		err      string
+	}{
+		"keys value": {
+			resource: resourceConsulKeys(),
+			config:   map[string]interface{}{"path": "test", "value": ""},
+		},
+		"keys value_base64": {
+			resource: resourceConsulKeys(),
+			config:   map[string]interface{}{"path": "test", "value_base64": "Zm9v"},
+		},
+		"keys both": {
+			resource: resourceConsulKeys(),
+			config:   map[string]interface{}{"path": "test", "value": "foo", "value_base64": "Zm9v"},
+			err:      `only one of value and value_base64 can be set for key "test"`,
+		},
+		"keys none": {
+			resource: resourceConsulKeys(),
+			config:   map[string]interface{}{"path": "test"},
+			err:      `one of value or value_base64 must be set for key "test"`,
+		},
+		"keys read": {
+			resource: resourceConsulKeys(),
+			config:   map[string]interface{}{"path": "test", "name": "test", "default": "foo"},
+		},
+		"keys unknown": {
+			resource: resourceConsulKeys(),
+			config:   map[string]interface{}{"path": "test", "value": unknownVariableValue},
+		},
+		"key_prefix value": {
+			resource: resourceConsulKeyPrefix(),
+			config:   map[string]interface{}{"path": "test", "value": ""},
+		},
+		"key_prefix both": {
+			resource: resourceConsulKeyPrefix(),
+			config:   map[string]interface{}{"path": "test", "value": "foo", "value_base64": "Zm9v"},
+			err:      `only one of value and value_base64 can be set for key "test"`,
+		},
+		"key_prefix none": {
+			resource: resourceConsulKeyPrefix(),
+			config:   map[string]interface{}{"path": "test"},
+			err:      `one of value or value_base64 must be set for key "test"`,
+		},
+		"key_prefix unknown": {
+			resource: resourceConsulKeyPrefix(),
+			config:   map[string]interface{}{"path": "test", "value_base64": unknownVariableValue},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			attr := "key"
+			raw := map[string]interface{}{}
+			if _, ok := tc.resource.Schema["subkey"]; ok {
+				attr = "subkey"
+				raw["path_prefix"] = "prefix/"
+			}
+			raw[attr] = []interface{}{tc.config}
+
+			_, err := tc.resource.Diff(nil, terraform.NewResourceConfigRaw(raw), nil)
+			if tc.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestResourceConsulKeys_import(t *testing.T) {
+	kv, config := newFakeKV(t)
+
+	binary := []byte{0xff, 0xfe, 0x00, 0x80}
+	kv.set("test/name", []byte("web"), 2)
+	kv.set("test/binary", binary, 0)
+
+	r := resourceConsulKeys()
+	d := r.Data(nil)
+	d.SetId("dc2:test/name,test/binary")
+
+	res, err := resourceConsulKeysImport(d, config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected 1 resource, got %d", len(res))
+	}
+	if res[0].Id() != "consul" {
+		t.Fatalf("unexpected ID %q", res[0].Id())
+	}
+	if dc := res[0].Get("datacenter"); dc != "dc2" {
+		t.Fatalf("expected datacenter dc2, got %v", dc)
+	}
+
+	keys := map[string]map[string]interface{}{}
+	for _, raw := range res[0].Get("key").(*schema.Set).List() {
+		key := raw.(map[string]interface{})
+		keys[key["path"].(string)] = key
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys, got %v", keys)
+	}
+	if key := keys["test/name"]; key["value"] != "web" || key["flags"] != 2 {
+		t.Fatalf("unexpected key %v", key)
+	}
+	encoded := base64.StdEncoding.EncodeToString(binary)
+	if key := keys["test/binary"]; key["value"] != "" || key["value_base64"] != encoded {
+		t.Fatalf("unexpected key %v", key)
+	}
+
+	// Namespace and partition
+	d = r.Data(nil)
+	d.SetId("part/ns/:test/name")
+	res, err = resourceConsulKeysImport(d, config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ns := res[0].Get("namespace"); ns != "ns" {
+		t.Fatalf("expected namespace ns, got %v", ns)
+	}
+	if partition := res[0].Get("partition"); partition != "part" {
+		t.Fatalf("expected partition part, got %v", partition)
+	}
+	if dc := res[0].Get("datacenter"); dc != "dc1" {
+		t.Fatalf("expected the datacenter of the provider, got %v", dc)
+	}
+
+	for _, id := range []string{"test/name", "dc1:", "dc1:test/name,", "ns/dc1:test/name"} {
+		d = r.Data(nil)
+		d.SetId(id)
+		_, err := resourceConsulKeysImport(d, config)
+		if err == nil || !strings.Contains(err.Error(), "expected ID of the form") {
+			t.Fatalf("unexpected error for %q: %v", id, err)
+		}
+	}
+
+	d = r.Data(nil)
+	d.SetId("dc1:test/unknown")
+	_, err = resourceConsulKeysImport(d, config)
+	if err == nil || !strings.Contains(err.Error(), "the key does not exist") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// lockMaxWait is the maximum time spent in a blocking query while waiting for
+// a lock. The lock delay of a released lock does not trigger an update of the
+// key so it must be polled.
+const lockMaxWait = 10 * time.Second
+
+func resourceConsulLock() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceConsulLockCreate,
+		Read:   resourceConsulLockRead,
+		Delete: resourceConsulLockDelete,
+
+		Description: "The `consul_lock` resource acquires a lock in the Consul KV store for the duration of a Terraform run so that concurrent runs against the same Consul cluster wait for each other. The lock is held using a session that is renewed while Terraform is running and is released once Terraform is done with the provider, the resource is therefore planned for creation on each run.",
+
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The path of the key used as the lock.",
+			},
+
+			"value": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The value to write in the key while the lock is held, for example to identify the pipeline holding it.",
+			},
+
+			"ttl": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "15s",
+				Description:  "The TTL of the session holding the lock. The session is renewed while Terraform is running, if the provider is killed the lock is released once the TTL expires.",
+				ValidateFunc: makeValidationFunc("ttl", []interface{}{validateDurationMin("10s")}),
+			},
+
+			"lock_delay": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "15s",
+				Description:  "How long the lock cannot be acquired after the session holding it has been invalidated.",
+				ValidateFunc: makeValidationFunc("lock_delay", []interface{}{validateDurationMin("0s")}),
+			},
+
+			"timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "5m",
+				Description:  "How long to wait for the lock to be available before failing.",
+				ValidateFunc: makeValidationFunc("timeout", []interface{}{validateDurationMin("0s")}),
+			},
+
+			"session": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the session holding the lock.",
+			},
+
+			"datacenter": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "The datacenter to use. This overrides the agent's default datacenter and the datacenter in the provider setup.",
+			},
+
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The namespace of the lock.",
+			},
+
+			"partition": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The partition of the lock.",
+			},
+		},
+	}
+}
+
+func resourceConsulLockCreate(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, wOpts := getClient(d, meta)
+
+	path := d.Get("path").(string)
+	ttl := d.Get("ttl").(string)
+	lockDelay, err := time.ParseDuration(d.Get("lock_delay").(string))
+	if err != nil {
+		return fmt.Errorf("failed to parse lock_delay: %v", err)
+	}
+	timeout, err := time.ParseDuration(d.Get("timeout").(string))
+	if err != nil {
+		return fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	// The key is deleted when the session is invalidated so that a lock
+	// whose holder died does not stay around
+	session, _, err := client.Session().Create(&consulapi.SessionEntry{
+		Name:      fmt.Sprintf("Terraform lock on %s", path),
+		TTL:       ttl,
+		LockDelay: lockDelay,
+		Behavior:  consulapi.SessionBehaviorDelete,
+	}, wOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create session for lock %q: %v", path, err)
+	}
+
+	// The session must be renewed while we are waiting for the lock too
+	lock := heldLocks.track(meta.(*Config), path, session, ttl, wOpts)
+
+	pair := &consulapi.KVPair{
+		Key:     path,
+		Value:   []byte(d.Get("value").(string)),
+		Session: session,
+	}
+	if err := acquireLock(client.KV(), pair, qOpts, wOpts, timeout); err != nil {
+		heldLocks.release(lock)
+		return err
+	}
+
+	log.Printf("[DEBUG] Acquired lock %q with session %s", path, session)
+
+	d.SetId(fmt.Sprintf("%s-%s", path, session))
+	sw := newStateWriter(d)
+	sw.set("session", session)
+	sw.set("datacenter", wOpts.Datacenter)
+	return sw.error()
+}
+
+func resourceConsulLockRead(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, _ := getClient(d, meta)
+
+	path := d.Get("path").(string)
+	pair, _, err := client.KV().Get(path, qOpts)
+	if err != nil {
+		return fmt.Errorf("failed to read lock %q: %v", path, err)
+	}
+
+	// The lock is released at the end of each run, it must be acquired again
+	// on the next one
+	if pair == nil || pair.Session != d.Get("session").(string) {
+		log.Printf("[DEBUG] Lock %q is no longer held by session %s", path, d.Get("session"))
+		d.SetId("")
+		return nil
+	}
+
+	return nil
+}
+
+func resourceConsulLockDelete(d *schema.ResourceData, meta interface{}) error {
+	client, _, wOpts := getClient(d, meta)
+
+	path := d.Get("path").(string)
+	session := d.Get("session").(string)
+
+	// The lock is released when the provider stops, if it is still held by
+	// this provider it can be released now
+	if heldLocks.releaseSession(session) {
+		d.SetId("")
+		return nil
+	}
+
+	pair := &consulapi.KVPair{Key: path, Session: session}
+	if _, _, err := client.KV().Release(pair, wOpts); err != nil {
+		return fmt.Errorf("failed to release lock %q: %v", path, err)
+	}
+	if _, err := client.Session().Destroy(session, wOpts); err != nil {
+		return fmt.Errorf("failed to destroy session %q: %v", session, err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// acquireLock tries to acquire the lock until it succeeds or the timeout
+// expires, waiting for the key to change between attempts.
+func acquireLock(kv *consulapi.KV, pair *consulapi.KVPair, qOpts *consulapi.QueryOptions, wOpts *consulapi.WriteOptions, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	var index uint64
+	var holder string
+	for {
+		acquired, _, err := kv.Acquire(pair, wOpts)
+		if err != nil {
+			return fmt.Errorf("failed to acquire lock %q: %v", pair.Key, err)
+		}
+		if acquired {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if holder == "" {
+				return fmt.Errorf("timeout while waiting for lock %q", pair.Key)
+			}
+			return fmt.Errorf("timeout while waiting for lock %q held by session %s", pair.Key, holder)
+		}
+		if remaining > lockMaxWait {
+			remaining = lockMaxWait
+		}
+
+		log.Printf("[DEBUG] Lock %q is not available, waiting %s", pair.Key, remaining)
+
+		opts := *qOpts
+		opts.WaitIndex = index
+		opts.WaitTime = remaining
+		current, qMeta, err := kv.Get(pair.Key, &opts)
+		if err != nil {
+			return fmt.Errorf("failed to read lock %q: %v", pair.Key, err)
+		}
+		holder = ""
+		if current != nil {
+			holder = current.Session
+		}
+		index = qMeta.LastIndex
+	}
+}
+
+// heldLocks keeps track of the locks acquired by the provider so that their
+// sessions are renewed while Terraform is running and released once
+// Terraform is done with the provider.
+var heldLocks = &lockTracker{locks: map[string]*heldLock{}}
+
+type heldLock struct {
+	config  *Config
+	path    string
+	session string
+	wOpts   *consulapi.WriteOptions
+	doneCh  chan struct{}
+	stopped chan struct{}
+}
+
+type lockTracker struct {
+	mu    sync.Mutex
+	locks map[string]*heldLock
+}
+
+func (t *lockTracker) track(config *Config, path, session, ttl string, wOpts *consulapi.WriteOptions) *heldLock {
+	lock := &heldLock{
+		config:  config,
+		path:    path,
+		session: session,
+		wOpts:   wOpts,
+		doneCh:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(lock.stopped)
+
+		if err := lock.renew(ttl); err != nil {
+			log.Printf("[ERROR] Failed to renew session %s of lock %q, the lock is lost: %v", session, path, err)
+		}
+	}()
+
+	t.mu.Lock()
+	t.locks[session] = lock
+	t.mu.Unlock()
+
+	return lock
+}
+
+// writeOptions returns the options to use for the requests about the lock.
+// The token the provider got from its auth method may have been replaced
+// since the lock was acquired, the current one must be used.
+func (l *heldLock) writeOptions() *consulapi.WriteOptions {
+	opts := *l.wOpts
+	if l.config.isLoginToken(opts.Token) {
+		opts.Token = l.config.token()
+	}
+	return &opts
+}
+
+// renew renews the session of the lock until doneCh is closed and then
+// destroys it. It works like Session().RenewPeriodic() but gets the options
+// again before each request.
+func (l *heldLock) renew(initialTTL string) error {
+	session := l.config.client.Session()
+
+	ttl, err := time.ParseDuration(initialTTL)
+	if err != nil {
+		return err
+	}
+
+	wait := ttl / 2
+	lastRenewal := time.Now()
+	var lastErr error
+	for {
+		if time.Since(lastRenewal) > ttl {
+			return lastErr
+		}
+
+		select {
+		case <-time.After(wait):
+			entry, _, err := session.Renew(l.session, l.writeOptions())
+			if err != nil {
+				wait = time.Second
+				lastErr = err
+				continue
+			}
+			if entry == nil {
+				return consulapi.ErrSessionExpired
+			}
+
+			// Consul may have changed the TTL
+			ttl, _ = time.ParseDuration(entry.TTL)
+			wait = ttl / 2
+			lastRenewal = time.Now()
+
+		case <-l.doneCh:
+			if _, err := session.Destroy(l.session, l.writeOptions()); err != nil {
+				log.Printf("[WARN] Failed to destroy session %s of lock %q: %v", l.session, l.path, err)
+			}
+			return nil
+		}
+	}
+}
+
+// release releases the lock and destroys its session.
+func (t *lockTracker) release(lock *heldLock) {
+	t.mu.Lock()
+	delete(t.locks, lock.session)
+	t.mu.Unlock()
+
+	pair := &consulapi.KVPair{Key: lock.path, Session: lock.session}
+	if _, _, err := lock.config.client.KV().Release(pair, lock.writeOptions()); err != nil {
+		log.Printf("[WARN] Failed to release lock %q: %v", lock.path, err)
+	}
+
+	close(lock.doneCh)
+	<-lock.stopped
+	log.Printf("[DEBUG] Released lock %q", lock.path)
+}
+
+// releaseSession releases the lock held with the given session, it returns
+// false when the session is not tracked.
+func (t *lockTracker) releaseSession(session string) bool {
+	t.mu.Lock()
+	lock, ok := t.locks[session]
+	t.mu.Unlock()
+
+	if ok {
+		t.release(lock)
+	}
+	return ok
+}
+
+func (t *lockTracker) releaseAll() {
+	t.mu.Lock()
+	locks := make([]*heldLock, 0, len(t.locks))
+	for _, lock := range t.locks {
+		locks = append(locks, lock)
+	}
+	t.mu.Unlock()
+
+	for _, lock := range locks {
+		t.release(lock)
+	}
+}
+
+// ReleaseLocks releases all the locks acquired with the consul_lock resource.
+// It is meant to be called once the plugin has stopped serving requests.
+func ReleaseLocks() {
+	heldLocks.releaseAll()
+}
@@ -0,0 +1,209 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+// fakeLock is a lock held by another session until releaseAfter attempts to
+// acquire it have been made.
+type fakeLock struct {
+	mu           sync.Mutex
+	attempts     int
+	releaseAfter int
+	index        uint64
+}
+
+func (l *fakeLock) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch req.Method {
+	case http.MethodPut:
+		if req.URL.Query().Get("acquire") != "session" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		l.attempts++
+		_, _ = w.Write([]byte(fmt.Sprintf("%t", l.attempts > l.releaseAfter)))
+	case http.MethodGet:
+		l.index++
+		w.Header().Set("X-Consul-Index", fmt.Sprintf("%d", l.index))
+		_ = json.NewEncoder(w).Encode(consulapi.KVPairs{{Key: "lock", Session: "other"}})
+	}
+}
+
+func testLockKV(t *testing.T, h http.Handler) *consulapi.KV {
+	t.Helper()
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	client, err := consulapi.NewClient(&consulapi.Config{
+		Address: strings.TrimPrefix(server.URL, "http://"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return client.KV()
+}
+
+func TestAcquireLock(t *testing.T) {
+	l := &fakeLock{releaseAfter: 2}
+	kv := testLockKV(t, l)
+
+	pair := &consulapi.KVPair{Key: "lock", Session: "session"}
+	err := acquireLock(kv, pair, &consulapi.QueryOptions{}, &consulapi.WriteOptions{}, time.Minute)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if l.attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", l.attempts)
+	}
+}
+
+func TestAcquireLock_timeout(t *testing.T) {
+	l := &fakeLock{releaseAfter: 1000}
+	kv := testLockKV(t, l)
+
+	pair := &consulapi.KVPair{Key: "lock", Session: "session"}
+	err := acquireLock(kv, pair, &consulapi.QueryOptions{}, &consulapi.WriteOptions{}, 10*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	expected := `timeout while waiting for lock "lock" held by session other`
+	if err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err)
+	}
+}
+
+func TestHeldLock_currentToken(t *testing.T) {
+	var mu sync.Mutex
+	tokens := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := strings.TrimSuffix(req.URL.Path, "/session-id")
+		tokens[path] = append(tokens[path], req.Header.Get("X-Consul-Token"))
+		switch path {
+		case "/v1/session/renew":
+			_ = json.NewEncoder(w).Encode([]*consulapi.SessionEntry{{ID: "session-id", TTL: "20ms"}})
+		default:
+			_, _ = w.Write([]byte("true"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config := &Config{Address: strings.TrimPrefix(server.URL, "http://")}
+	client, err := config.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config.client = client
+	config.setLoginToken("login-token-1")
+
+	// The token from the auth method is replaced while the lock is held
+	lock := heldLocks.track(config, "lock", "session-id", "20ms", &consulapi.WriteOptions{Token: config.token()})
+	config.setLoginToken("login-token-2")
+	time.Sleep(50 * time.Millisecond)
+	heldLocks.release(lock)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/v1/session/renew", "/v1/kv/lock", "/v1/session/destroy"} {
+		if len(tokens[path]) == 0 {
+			t.Fatalf("expected requests to %s, got %v", path, tokens)
+		}
+		for _, token := range tokens[path] {
+			if token != "login-token-2" {
+				t.Fatalf("expected the current token to be used for %s, got %q", path, token)
+			}
+		}
+	}
+
+	// A token set in the resource is kept
+	lock = &heldLock{config: config, wOpts: &consulapi.WriteOptions{Token: "resource-token"}}
+	if token := lock.writeOptions().Token; token != "resource-token" {
+		t.Fatalf("expected the token of the resource to be used, got %q", token)
+	}
+}
+
+func TestAccConsulLock_basic(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers: providers,
+		CheckDestroy: func(s *terraform.State) error {
+			pair, _, err := client.KV().Get("test/lock", nil)
+			if err != nil {
+				return err
+			}
+			if pair != nil && pair.Session != "" {
+				return fmt.Errorf("lock is still held by session %s", pair.Session)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccConsulLockConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("consul_lock.test", "session"),
+					resource.TestCheckResourceAttr("consul_lock.test", "path", "test/lock"),
+					func(s *terraform.State) error {
+						session := s.RootModule().Resources["consul_lock.test"].Primary.Attributes["session"]
+						pair, _, err := client.KV().Get("test/lock", nil)
+						if err != nil {
+							return err
+						}
+						if pair == nil || pair.Session != session {
+							return fmt.Errorf("expected the lock to be held by %s, got %#v", session, pair)
+						}
+						if string(pair.Value) != "pipeline-1" {
+							return fmt.Errorf("unexpected value %q", pair.Value)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				// The lock is already held by the first resource
+				Config:      testAccConsulLockConfig_conflict,
+				ExpectError: regexp.MustCompile(`timeout while waiting for lock "test/lock" held by session`),
+			},
+		},
+	})
+}
+
+const testAccConsulLockConfig = `
+resource "consul_lock" "test" {
+  path  = "test/lock"
+  value = "pipeline-1"
+}
+`
+
+const testAccConsulLockConfig_conflict = `
+resource "consul_lock" "test" {
+  path  = "test/lock"
+  value = "pipeline-1"
+}
+
+resource "consul_lock" "other" {
+  path    = "test/lock"
+  timeout = "1s"
+}
+`
@@ -0,0 +1,227 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"fmt"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+func resourceConsulSession() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceConsulSessionCreate,
+		Read:   resourceConsulSessionRead,
+		Delete: resourceConsulSessionDelete,
+
+		Description: "The `consul_session` resource creates a [session](https://developer.hashicorp.com/consul/docs/dynamic-app-config/sessions) that can be used to acquire locks in the KV store or be referenced by a prepared query.\n\n~> **NOTE:** Terraform never renews the sessions it creates. A session with a `ttl` is invalidated once its TTL expires unless the application using it calls the [renew endpoint](https://developer.hashicorp.com/consul/api-docs/session#renew-session) in time, and Terraform then creates a new session on each apply. Omit `ttl` for sessions that must live as long as the resource.",
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "A human-readable name for the session.",
+			},
+
+			"node": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "The name of the node the session is associated with. Defaults to the node of the agent the provider is connected to.",
+			},
+
+			"checks": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "The IDs of the health checks associated with the session, the session is invalidated when one of them becomes critical. Defaults to the `serfHealth` check of the node.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"ttl": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				Description:      "The TTL of the session, between `10s` and `86400s`. Terraform does not renew the session, it is invalidated when the TTL expires unless the application using it renews it. Sessions without a TTL are only invalidated when they are deleted or when one of their checks becomes critical.",
+				ValidateFunc:     makeValidationFunc("ttl", []interface{}{validateDurationMin("10s")}),
+				DiffSuppressFunc: diffDuration,
+			},
+
+			"behavior": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      consulapi.SessionBehaviorRelease,
+				Description:  "What to do with the locks held by the session when it is invalidated, either `release` or `delete`.",
+				ValidateFunc: validation.StringInSlice([]string{consulapi.SessionBehaviorRelease, consulapi.SessionBehaviorDelete}, false),
+			},
+
+			"lock_delay": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				Description:      "How long the locks held by the session cannot be acquired after it has been invalidated, up to `60s`. Set it to `0s` to disable the lock delay. Defaults to `15s`.",
+				ValidateFunc:     makeValidationFunc("lock_delay", []interface{}{validateDurationMin("0s")}),
+				DiffSuppressFunc: diffDuration,
+			},
+
+			"datacenter": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "The datacenter to use. This overrides the agent's default datacenter and the datacenter in the provider setup.",
+			},
+
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The namespace to create the session within.",
+			},
+
+			"partition": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The partition to create the session within.",
+			},
+		},
+	}
+}
+
+func resourceConsulSessionCreate(d *schema.ResourceData, meta interface{}) error {
+	client, _, wOpts := getClient(d, meta)
+
+	body, err := sessionCreateRequest(d)
+	if err != nil {
+		return err
+	}
+
+	// Session().Create() does not send a lock delay of 0, Consul would use
+	// its default one instead.
+	var out struct{ ID string }
+	if _, err := client.Raw().Write("/v1/session/create", body, &out, wOpts); err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+
+	d.SetId(out.ID)
+
+	return resourceConsulSessionRead(d, meta)
+}
+
+// sessionCreateRequest returns the body of the request used to create the
+// session.
+func sessionCreateRequest(d *schema.ResourceData) (map[string]interface{}, error) {
+	body := map[string]interface{}{
+		"Behavior": d.Get("behavior").(string),
+	}
+
+	if v := d.Get("name").(string); v != "" {
+		body["Name"] = v
+	}
+	if v := d.Get("node").(string); v != "" {
+		body["Node"] = v
+	}
+	if v := d.Get("ttl").(string); v != "" {
+		body["TTL"] = v
+	}
+
+	var checks []string
+	for _, check := range d.Get("checks").([]interface{}) {
+		checks = append(checks, check.(string))
+	}
+	if len(checks) > 0 {
+		body["Checks"] = checks
+	}
+
+	if v, ok := d.GetOk("lock_delay"); ok {
+		lockDelay, err := time.ParseDuration(v.(string))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lock_delay: %v", err)
+		}
+		// Consul expects milliseconds, shorter delays are rounded up so that
+		// they are not disabled
+		ms := lockDelay / time.Millisecond
+		if lockDelay > 0 && ms == 0 {
+			ms = 1
+		}
+		body["LockDelay"] = fmt.Sprintf("%dms", ms)
+	}
+
+	return body, nil
+}
+
+func resourceConsulSessionRead(d *schema.ResourceData, meta interface{}) error {
+	client, qOpts, _ := getClient(d, meta)
+
+	session, _, err := client.Session().Info(d.Id(), qOpts)
+	if err != nil {
+		return fmt.Errorf("failed to read session %q: %v", d.Id(), err)
+	}
+	if session == nil {
+		// The session has expired or has been invalidated
+		d.SetId("")
+		return nil
+	}
+
+	sw := newStateWriter(d)
+	sw.set("name", session.Name)
+	sw.set("node", session.Node)
+	sw.set("checks", sessionChecks(session))
+	sw.set("ttl", session.TTL)
+	sw.set("behavior", session.Behavior)
+	sw.set("lock_delay", session.LockDelay.String())
+	sw.set("datacenter", qOpts.Datacenter)
+
+	return sw.error()
+}
+
+func resourceConsulSessionDelete(d *schema.ResourceData, meta interface{}) error {
+	client, _, wOpts := getClient(d, meta)
+
+	if _, err := client.Session().Destroy(d.Id(), wOpts); err != nil {
+		return fmt.Errorf("failed to delete session %q: %v", d.Id(), err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// sessionChecks returns the IDs of the checks of a session. Since Consul 1.7
+// they may be returned as node and service checks instead.
+func sessionChecks(session *consulapi.SessionEntry) []string {
+	if len(session.Checks) > 0 {
+		return session.Checks
+	}
+
+	checks := append([]string{}, session.NodeChecks...)
+	for _, check := range session.ServiceChecks {
+		checks = append(checks, check.ID)
+	}
+	return checks
+}
+
+// diffDuration suppresses the diff between two representations of the same
+// duration, like "60s" and "1m0s".
+func diffDuration(k, old, new string, d *schema.ResourceData) bool {
+	o, err := time.ParseDuration(old)
+	if err != nil {
+		return false
+	}
+	n, err := time.ParseDuration(new)
+	if err != nil {
+		return false
+	}
+	return o == n
+}
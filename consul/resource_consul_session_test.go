@@ -0,0 +1,254 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccConsulSession_basic(t *testing.T) {
+	providers, client := startTestServer(t)
+
+	resource.Test(t, resource.TestCase{
+		Providers: providers,
+		CheckDestroy: func(s *terraform.State) error {
+			sessions, _, err := client.Session().List(nil)
+			if err != nil {
+				return err
+			}
+			for _, session := range sessions {
+				if session.Name == "test-session" {
+					return fmt.Errorf("session %s still exists", session.ID)
+				}
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccConsulSessionConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("consul_session.test", "id"),
+					resource.TestCheckResourceAttrSet("consul_session.test", "node"),
+					resource.TestCheckResourceAttr("consul_session.test", "name", "test-session"),
+					resource.TestCheckResourceAttr("consul_session.test", "ttl", "60s"),
+					resource.TestCheckResourceAttr("consul_session.test", "behavior", "delete"),
+					resource.TestCheckResourceAttr("consul_session.test", "lock_delay", "1m0s"),
+					resource.TestCheckResourceAttr("consul_session.test", "checks.#", "1"),
+					resource.TestCheckResourceAttr("consul_session.test", "checks.0", "serfHealth"),
+					resource.TestCheckResourceAttr("consul_session.test", "datacenter", "dc1"),
+				),
+			},
+			{
+				Config: testAccConsulSessionConfig_dataSource,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.consul_sessions.node", "sessions.#", "1"),
+					resource.TestCheckResourceAttrPair("data.consul_sessions.node", "sessions.0.id", "consul_session.test", "id"),
+					resource.TestCheckResourceAttr("data.consul_sessions.node", "sessions.0.name", "test-session"),
+					resource.TestCheckResourceAttr("data.consul_sessions.node", "sessions.0.ttl", "60s"),
+					resource.TestCheckResourceAttr("data.consul_sessions.node", "sessions.0.behavior", "delete"),
+					resource.TestCheckResourceAttr("data.consul_sessions.node", "sessions.0.lock_delay", "1m0s"),
+					resource.TestCheckResourceAttr("data.consul_sessions.node", "sessions.0.checks.0", "serfHealth"),
+					resource.TestCheckResourceAttr("data.consul_sessions.unknown", "sessions.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+func TestDiffDuration(t *testing.T) {
+	cases := []struct {
+		old, new string
+		equal    bool
+	}{
+		{"60s", "1m0s", true},
+		{"15s", "15s", true},
+		{"15s", "16s", false},
+		{"", "15s", false},
+		{"foo", "foo", false},
+	}
+
+	for _, tc := range cases {
+		if got := diffDuration("ttl", tc.old, tc.new, nil); got != tc.equal {
+			t.Errorf("diffDuration(%q, %q) = %v, expected %v", tc.old, tc.new, got, tc.equal)
+		}
+	}
+}
+
+func TestSessionCreateRequest(t *testing.T) {
+	cases := map[string]struct {
+		raw      map[string]interface{}
+		expected map[string]interface{}
+	}{
+		"defaults": {
+			raw: map[string]interface{}{},
+			expected: map[string]interface{}{
+				"Behavior": "release",
+			},
+		},
+		"all": {
+			raw: map[string]interface{}{
+				"name":       "test",
+				"node":       "node-1",
+				"checks":     []interface{}{"serfHealth", "service:web"},
+				"ttl":        "60s",
+				"behavior":   "delete",
+				"lock_delay": "1m",
+			},
+			expected: map[string]interface{}{
+				"Name":      "test",
+				"Node":      "node-1",
+				"Checks":    []string{"serfHealth", "service:web"},
+				"TTL":       "60s",
+				"Behavior":  "delete",
+				"LockDelay": "60000ms",
+			},
+		},
+		"no lock delay": {
+			raw: map[string]interface{}{
+				"lock_delay": "0s",
+			},
+			expected: map[string]interface{}{
+				"Behavior":  "release",
+				"LockDelay": "0ms",
+			},
+		},
+		"short lock delay": {
+			raw: map[string]interface{}{
+				"lock_delay": "500us",
+			},
+			expected: map[string]interface{}{
+				"Behavior":  "release",
+				"LockDelay": "1ms",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourceConsulSession().Schema, tc.raw)
+			body, err := sessionCreateRequest(d)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(body, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, body)
+			}
+		})
+	}
+}
+
+func TestResourceConsulSession_lockDelayValidation(t *testing.T) {
+	validate := resourceConsulSession().Schema["lock_delay"].ValidateFunc
+
+	for _, v := range []string{"0s", "15s", "1m"} {
+		if _, errs := validate(v, "lock_delay"); len(errs) != 0 {
+			t.Errorf("expected %q to be valid, got %v", v, errs)
+		}
+	}
+	for _, v := range []string{"-1s", "foo"} {
+		if _, errs := validate(v, "lock_delay"); len(errs) == 0 {
+			t.Errorf("expected %q to be invalid", v)
+		}
+	}
+}
+
+func TestResourceConsulSession_read(t *testing.T) {
+	// The sessions are read the same way by the resource and the data source
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v1/session/info/session-id", "/v1/session/node/node-1":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+			{
+				"ID":         "session-id",
+				"Name":       "test",
+				"Node":       "node-1",
+				"NodeChecks": []string{"serfHealth"},
+				"ServiceChecks": []map[string]interface{}{
+					{"ID": "service:web"},
+				},
+				"TTL":       "60s",
+				"Behavior":  "delete",
+				"LockDelay": 0,
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	config := &Config{
+		Address:    strings.TrimPrefix(server.URL, "http://"),
+		Datacenter: "dc1",
+	}
+	client, err := config.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config.client = client
+
+	d := schema.TestResourceDataRaw(t, resourceConsulSession().Schema, map[string]interface{}{})
+	d.SetId("session-id")
+	if err := resourceConsulSessionRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"name":       "test",
+		"node":       "node-1",
+		"checks":     []interface{}{"serfHealth", "service:web"},
+		"ttl":        "60s",
+		"behavior":   "delete",
+		"lock_delay": "0s",
+		"datacenter": "dc1",
+	}
+	for k, v := range expected {
+		if got := d.Get(k); !reflect.DeepEqual(got, v) {
+			t.Errorf("expected %s to be %#v, got %#v", k, v, got)
+		}
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceConsulSessions().Schema, map[string]interface{}{
+		"node": "node-1",
+	})
+	if err := dataSourceConsulSessionsRead(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	delete(expected, "datacenter")
+	expected["id"] = "session-id"
+	sessions := d.Get("sessions").([]interface{})
+	if len(sessions) != 1 || !reflect.DeepEqual(sessions[0], expected) {
+		t.Fatalf("expected %#v, got %#v", expected, sessions)
+	}
+}
+
+const testAccConsulSessionConfig = `
+resource "consul_session" "test" {
+  name       = "test-session"
+  ttl        = "60s"
+  behavior   = "delete"
+  lock_delay = "60s"
+}
+`
+
+const testAccConsulSessionConfig_dataSource = testAccConsulSessionConfig + `
+data "consul_sessions" "node" {
+  node = consul_session.test.node
+}
+
+data "consul_sessions" "unknown" {
+  node = "unknown"
+}
+`
@@ -6,13 +6,13 @@ package consul
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
 	"strings"
 
 	consulapi "github.com/hashicorp/consul/api"
-	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 	"github.com/hashicorp/terraform-provider-consul/consul/auth"
 	"github.com/mitchellh/mapstructure"
@@ -45,7 +45,7 @@ func Provider() terraform.ResourceProvider {
 					"CONSUL_ADDRESS",
 					"CONSUL_HTTP_ADDR",
 				}, "localhost:8500"),
-				Description: `The HTTP(S) API address of the agent to use. Defaults to "127.0.0.1:8500".`,
+				Description: `The HTTP(S) API address of the agent to use. Defaults to "127.0.0.1:8500". Use the "unix://" prefix to connect to the agent using a Unix domain socket.`,
 			},
 
 			"scheme": {
@@ -124,6 +124,27 @@ func Provider() terraform.ResourceProvider {
 				Description: `Boolean value to disable SSL certificate verification; setting this value to true is not recommended for production use. Only use this with scheme set to "https".`,
 			},
 
+			"tls_server_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("CONSUL_TLS_SERVER_NAME", ""),
+				Description: "The server name to use as the SNI host when connecting via TLS and to verify the certificate of the agent, e.g. `server.dc1.consul`. Can also be specified with the `CONSUL_TLS_SERVER_NAME` environment variable.",
+			},
+
+			"tls_min_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"TLSv1_0", "TLSv1_1", "TLSv1_2", "TLSv1_3"}, false),
+				Description:  "The minimum TLS version to use when connecting to the agent, one of `TLSv1_0`, `TLSv1_1`, `TLSv1_2` or `TLSv1_3`. Defaults to TLS 1.2.",
+			},
+
+			"tls_cipher_suites": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The TLS cipher suites to use when connecting to the agent, e.g. `TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256`. Cannot be used when `tls_min_version` is `TLSv1_3`.",
+			},
+
 			"token": {
 				Type:      schema.TypeString,
 				Optional:  true,
@@ -136,10 +157,12 @@ func Provider() terraform.ResourceProvider {
 			},
 
 			"auth_jwt": {
-				Type:        schema.TypeList,
-				Optional:    true,
-				MaxItems:    1,
-				Description: "Authenticates to Consul using a JWT authentication method.",
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				Description:   "Authenticates to Consul using a JWT authentication method.",
+				Deprecated:    "auth_jwt is deprecated, use auth_login_jwt instead.",
+				ConflictsWith: []string{"auth_login_jwt"},
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"auth_method": {
@@ -169,6 +192,75 @@ func Provider() terraform.ResourceProvider {
 				},
 			},
 
+			"logout_on_exit": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether to destroy the token created when logging in with an auth method once Terraform is done with the provider. By default the token is kept until it expires.",
+			},
+
+			"max_requests_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.FloatAtLeast(0),
+				Description:  "The maximum number of requests per second sent to Consul by the provider, shared by all the resources and data sources. Defaults to 0 to not limit the rate of requests.",
+			},
+
+			"max_concurrent_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: makeValidationFunc("max_concurrent_requests", []interface{}{validateIntMin(0)}),
+				Description:  "The maximum number of requests sent to Consul at the same time by the provider, shared by all the resources and data sources. Blocking queries are not counted since they can be held by Consul for several minutes. Defaults to 0 to not limit the number of concurrent requests.",
+			},
+
+			"retry": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Retries the requests that fail because of a connection error or a transient error returned by Consul, like the ones sent during a leader election or when the request rate limit is reached. The requests writing data are only retried when Consul has provably not handled them, so that tokens or sessions are not created twice.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"max_attempts": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      4,
+							ValidateFunc: makeValidationFunc("max_attempts", []interface{}{validateIntMin(1)}),
+							Description:  "The maximum number of times a request is sent, including the first attempt.",
+						},
+						"min_backoff": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "1s",
+							ValidateFunc: makeValidationFunc("min_backoff", []interface{}{validateDurationMin("0s")}),
+							Description:  "The time to wait before the first retry, it is doubled after each attempt.",
+						},
+						"max_backoff": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "30s",
+							ValidateFunc: makeValidationFunc("max_backoff", []interface{}{validateDurationMin("0s")}),
+							Description:  "The maximum time to wait between two attempts. The `Retry-After` header returned by Consul is honored up to this value.",
+						},
+						"retryable_status_codes": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeInt},
+							Description: "The HTTP status codes that should be retried. Defaults to `[429, 500, 502, 503, 504]`. A 500 error is only retried when it is caused by the state of the cluster, like a leader election, and 502 and 504 errors are only retried for the requests that only read data.",
+						},
+					},
+				},
+			},
+
+			"log_level": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "debug",
+				ValidateFunc: validation.StringInSlice(logLevels, false),
+				Description:  "The level of the logs written by the provider, one of `trace`, `debug`, `info`, `warn` or `error`. The requests sent to Consul are logged at the `debug` level and their headers and bodies at the `trace` level, with the tokens and the values of the KV store redacted. Only the first 4KB of the bodies are logged and the snapshots are never logged.",
+			},
+
 			"namespace": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -206,9 +298,12 @@ func Provider() terraform.ResourceProvider {
 			"consul_services":                          dataSourceConsulServices(),
 			"consul_keys":                              dataSourceConsulKeys(),
 			"consul_key_prefix":                        dataSourceConsulKeyPrefix(),
+			"consul_key_tree":                          dataSourceConsulKeyTree(),
 			"consul_acl_auth_method":                   dataSourceConsulACLAuthMethod(),
 			"consul_acl_policy":                        dataSourceConsulACLPolicy(),
 			"consul_acl_role":                          dataSourceConsulACLRole(),
+			"consul_acl_templated_policies":            dataSourceConsulACLTemplatedPolicies(),
+			"consul_acl_templated_policy":              dataSourceConsulACLTemplatedPolicy(),
 			"consul_acl_token":                         dataSourceConsulACLToken(),
 			"consul_acl_token_secret_id":               dataSourceConsulACLTokenSecretID(),
 			"consul_network_segments":                  dataSourceConsulNetworkSegments(),
@@ -218,6 +313,8 @@ func Provider() terraform.ResourceProvider {
 			"consul_config_entry_v2_exported_services": dataSourceConsulConfigEntryV2ExportedServices(),
 			"consul_peering":                           dataSourceConsulPeering(),
 			"consul_peerings":                          dataSourceConsulPeerings(),
+			"consul_sessions":                          dataSourceConsulSessions(),
+			"consul_watch":                             dataSourceConsulWatch(),
 
 			// Aliases to limit the impact of rename of catalog
 			// datasources
@@ -235,6 +332,7 @@ func Provider() terraform.ResourceProvider {
 			"consul_acl_token_policy_attachment":       resourceConsulACLTokenPolicyAttachment(),
 			"consul_acl_token_role_attachment":         resourceConsulACLTokenRoleAttachment(),
 			"consul_acl_token":                         resourceConsulACLToken(),
+			"consul_acl_token_rotation":                resourceConsulACLTokenRotation(),
 			"consul_admin_partition":                   resourceConsulAdminPartition(),
 			"consul_agent_service":                     resourceConsulAgentService(),
 			"consul_autopilot_config":                  resourceConsulAutopilotConfig(),
@@ -250,6 +348,7 @@ func Provider() terraform.ResourceProvider {
 			"consul_intention":                         resourceConsulIntention(),
 			"consul_key_prefix":                        resourceConsulKeyPrefix(),
 			"consul_keys":                              resourceConsulKeys(),
+			"consul_lock":                              resourceConsulLock(),
 			"consul_license":                           resourceConsulLicense(),
 			"consul_namespace_policy_attachment":       resourceConsulNamespacePolicyAttachment(),
 			"consul_namespace_role_attachment":         resourceConsulNamespaceRoleAttachment(),
@@ -260,6 +359,7 @@ func Provider() terraform.ResourceProvider {
 			"consul_peering":                           resourceSourceConsulPeering(),
 			"consul_prepared_query":                    resourceConsulPreparedQuery(),
 			"consul_service":                           resourceConsulService(),
+			"consul_session":                           resourceConsulSession(),
 		},
 
 		ConfigureFunc: providerConfigure,
@@ -272,18 +372,15 @@ func Provider() terraform.ResourceProvider {
 }
 
 func providerConfigure(d *schema.ResourceData) (interface{}, error) {
-	// Initialize logger
-	logger := hclog.New(&hclog.LoggerOptions{
-		Name:   "consul-provider",
-		Level:  hclog.Debug,
-		Output: os.Stderr,
-	})
-
 	var config *Config
 	configRaw := d.Get("").(map[string]interface{})
 	if err := mapstructure.Decode(configRaw, &config); err != nil {
 		return nil, err
 	}
+
+	logger := newLogger(config.LogLevel)
+	config.logger = logger
+
 	logger.Debug("Initializing Consul client")
 	client, err := config.Client()
 	if err != nil {
@@ -311,6 +408,20 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		return nil, err
 	}
 
+	// Fallback to legacy auth_jwt for backward compatibility
+	legacyJWT := false
+	if authLogin == nil {
+		if _, ok := d.GetOk("auth_jwt"); ok {
+			// The login request uses the datacenter and token of the provider
+			_, wOpts := getOptions(d, config)
+			authLogin, err = auth.NewLegacyAuthLoginJWT(d, wOpts)
+			if err != nil {
+				return nil, err
+			}
+			legacyJWT = true
+		}
+	}
+
 	if authLogin != nil {
 		logger.Debug("Using auth login method", "method", authLogin.AuthMethodName())
 
@@ -327,44 +438,17 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 
 		token, err := authLogin.Login(client)
 		if err != nil {
+			if legacyJWT {
+				return nil, fmt.Errorf("failed to login using JWT auth method %q: %v", authLogin.AuthMethodName(), err)
+			}
 			return nil, fmt.Errorf("failed to login using auth method %q: %v", authLogin.AuthMethodName(), err)
 		}
-		config.Token = token
+		config.setLoginToken(token)
+		config.authLogin = authLogin
 		logger.Debug("Successfully authenticated using auth method", "method", authLogin.AuthMethodName())
-	} else {
-		// Fallback to legacy auth_jwt for backward compatibility
-		authJWT := d.Get("auth_jwt").([]interface{})
-		if len(authJWT) > 0 {
-			authConfig := authJWT[0].(map[string]interface{})
-			authMethod := authConfig["auth_method"].(string)
-			tfeWorkloadIdentity := authConfig["use_terraform_cloud_workload_identity"].(bool)
-			bearerToken := authConfig["bearer_token"].(string)
-
-			if tfeWorkloadIdentity {
-				bearerToken = os.Getenv("TFC_WORKLOAD_IDENTITY_TOKEN")
-				if bearerToken == "" {
-					return nil, fmt.Errorf("auth_jwt.use_terraform_cloud_workload_identity has been set but no token found in TFC_WORKLOAD_IDENTITY_TOKEN environment variable")
-				}
-
-			} else if bearerToken == "" {
-				return nil, fmt.Errorf("either auth_jwt.bearer_token or auth_jwt.use_terraform_cloud_workload_identity should be set")
-			}
-
-			meta := map[string]string{}
-			for k, v := range authConfig["meta"].(map[string]interface{}) {
-				meta[k] = v.(string)
-			}
 
-			_, wOpts := getOptions(d, config)
-			token, _, err := client.ACL().Login(&consulapi.ACLLoginParams{
-				AuthMethod:  authMethod,
-				BearerToken: bearerToken,
-				Meta:        meta,
-			}, wOpts)
-			if err != nil {
-				return nil, fmt.Errorf("failed to login using JWT auth method %q: %v", authMethod, err)
-			}
-			config.Token = token.SecretID
+		if config.LogoutOnExit {
+			loginTokens.track(client, token)
 		}
 	}
 
@@ -381,7 +465,6 @@ func getClient(d *schema.ResourceData, meta interface{}) (*consulapi.Client, *co
 
 func getOptions(d *schema.ResourceData, meta interface{}) (*consulapi.QueryOptions, *consulapi.WriteOptions) {
 	config := meta.(*Config)
-	client := config.client
 	var dc, token, namespace, partition string
 
 	if v, ok := d.GetOk("datacenter"); ok {
@@ -401,18 +484,23 @@ func getOptions(d *schema.ResourceData, meta interface{}) (*consulapi.QueryOptio
 		if config.Datacenter != "" {
 			dc = config.Datacenter
 		} else {
-			info, _ := client.Agent().Self()
-			if info != nil {
-				dc = info["Config"]["Datacenter"].(string)
+			// The datacenter of the agent is only looked up once it is
+			// needed since the token may not have the agent:read
+			// permission, Consul then uses the datacenter of the agent
+			// anyway.
+			var err error
+			dc, err = config.getAgentDatacenter()
+			if err != nil {
+				log.Printf("[WARN] %v", err)
 			}
 		}
 	}
 
 	if token == "" {
-		if config.Token != "" {
-			// Fall back to provider-level token when no resource-level override
-			token = config.Token
-		}
+		// Fall back to provider-level token when no resource-level override,
+		// it may have been replaced since the provider was configured if the
+		// token from the auth method expired.
+		token = config.token()
 	}
 
 	qOpts := &consulapi.QueryOptions{
@@ -1,9 +1,15 @@
 package consul
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
 
 func TestGetOptions_TokenFallback(t *testing.T) {
@@ -51,3 +57,103 @@ func TestGetOptions_ResourceTokenOverride(t *testing.T) {
 		t.Errorf("Expected WriteOptions.Token to be 'resource-level-token', got '%s'", wOpts.Token)
 	}
 }
+
+func TestGetOptions_AgentDatacenterCached(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/agent/self" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+		if token := req.Header.Get("X-Consul-Token"); token != "login-token" {
+			t.Errorf("expected the login token to be used, got %q", token)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"Config": map[string]interface{}{
+				"Datacenter": "agent-dc",
+			},
+		})
+	}))
+	defer server.Close()
+
+	config := &Config{Address: strings.TrimPrefix(server.URL, "http://")}
+	client, err := config.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config.client = client
+	config.Token = "login-token"
+
+	d := schema.TestResourceDataRaw(t, make(map[string]*schema.Schema), make(map[string]interface{}))
+	for i := 0; i < 3; i++ {
+		qOpts, wOpts := getOptions(d, config)
+		if qOpts.Datacenter != "agent-dc" || wOpts.Datacenter != "agent-dc" {
+			t.Fatalf("expected datacenter 'agent-dc', got '%s' and '%s'", qOpts.Datacenter, wOpts.Datacenter)
+		}
+	}
+
+	if requests != 1 {
+		t.Fatalf("expected the agent to be queried once, got %d requests", requests)
+	}
+}
+
+func TestGetOptions_AgentDatacenterError(t *testing.T) {
+	var denied atomic.Bool
+	denied.Store(true)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		if denied.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("Permission denied"))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"Config": map[string]interface{}{
+				"Datacenter": "agent-dc",
+			},
+		})
+	}))
+	defer server.Close()
+
+	// The token may not be allowed to read the agent, this must not prevent
+	// the provider from being configured
+	p := Provider().(*schema.Provider)
+	raw := map[string]interface{}{
+		"address": strings.TrimPrefix(server.URL, "http://"),
+	}
+	if err := p.Configure(terraform.NewResourceConfigRaw(raw)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("the agent must only be queried when needed, got %d requests", n)
+	}
+	config := p.Meta().(*Config)
+
+	// The agent is not queried when the datacenter is set in the resource
+	d := schema.TestResourceDataRaw(t, map[string]*schema.Schema{
+		"datacenter": {Type: schema.TypeString, Optional: true},
+	}, map[string]interface{}{"datacenter": "dc2"})
+	if qOpts, _ := getOptions(d, config); qOpts.Datacenter != "dc2" {
+		t.Fatalf("expected datacenter 'dc2', got '%s'", qOpts.Datacenter)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("the agent must only be queried when needed, got %d requests", n)
+	}
+
+	// Consul uses the datacenter of the agent when the lookup fails
+	d = schema.TestResourceDataRaw(t, make(map[string]*schema.Schema), make(map[string]interface{}))
+	if qOpts, _ := getOptions(d, config); qOpts.Datacenter != "" {
+		t.Fatalf("expected no datacenter, got '%s'", qOpts.Datacenter)
+	}
+	if _, err := config.getAgentDatacenter(); err == nil || !strings.Contains(err.Error(), "Permission denied") {
+		t.Fatalf("expected the error to be returned, got %v", err)
+	}
+
+	// A failed lookup must not be cached
+	denied.Store(false)
+	if qOpts, _ := getOptions(d, config); qOpts.Datacenter != "agent-dc" {
+		t.Fatalf("expected datacenter 'agent-dc', got '%s'", qOpts.Datacenter)
+	}
+}
@@ -105,6 +105,25 @@ func TestResourceProvider(t *testing.T) {
 				}`,
 			ExpectError: regexp.MustCompile("either auth_jwt.bearer_token or auth_jwt.use_terraform_cloud_workload_identity should be set"),
 		},
+		"auth_jwt_conflicts_with_auth_login_jwt": {
+			Config: `
+				provider "consul" {
+					address = "demo.consul.io:80"
+					auth_jwt {
+						auth_method  = "jwt"
+						bearer_token = "foo"
+					}
+					auth_login_jwt {
+						auth_method  = "jwt"
+						bearer_token = "foo"
+					}
+				}
+
+				data "consul_key_prefix" "app" {
+					path_prefix = "test"
+				}`,
+			ExpectError: regexp.MustCompile(`"auth_jwt": conflicts with auth_login_jwt`),
+		},
 		"auth_jwt_tfc_workload_identity": {
 			Config: `
 				provider "consul" {
@@ -0,0 +1,257 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRetryableStatusCodes are the status codes retried when
+// retryable_status_codes is not set: rate limiting by the Consul servers and
+// errors returned during leader elections.
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// transientErrors are the messages Consul returns with a 500 status code for
+// errors that are worth retrying. The other 500 errors are usually permanent,
+// including most of the "rpc error making call" errors a client agent
+// forwards from the servers.
+var transientErrors = []string{
+	"No cluster leader",
+	"leadership lost",
+	"rpc error getting client",
+}
+
+// transientErrorPeekSize is how much of the body of a 500 response is read to
+// look for transientErrors.
+const transientErrorPeekSize = 4096
+
+// RetryConfig is the configuration of the retry block of the provider.
+type RetryConfig struct {
+	MaxAttempts          int    `mapstructure:"max_attempts"`
+	MinBackoff           string `mapstructure:"min_backoff"`
+	MaxBackoff           string `mapstructure:"max_backoff"`
+	RetryableStatusCodes []int  `mapstructure:"retryable_status_codes"`
+}
+
+// retryTransport retries the requests that failed because of a connection
+// error or a retryable status code, waiting longer between each attempt.
+type retryTransport struct {
+	http.RoundTripper
+
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	statusCodes map[int]struct{}
+}
+
+func newRetryTransport(rt http.RoundTripper, c *RetryConfig) (*retryTransport, error) {
+	minBackoff, err := time.ParseDuration(c.MinBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse retry.min_backoff: %v", err)
+	}
+	maxBackoff, err := time.ParseDuration(c.MaxBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse retry.max_backoff: %v", err)
+	}
+	if minBackoff > maxBackoff {
+		return nil, fmt.Errorf("retry.min_backoff (%s) must not be greater than retry.max_backoff (%s)", minBackoff, maxBackoff)
+	}
+	if c.MaxAttempts < 1 {
+		return nil, fmt.Errorf("retry.max_attempts must be at least 1, got %d", c.MaxAttempts)
+	}
+
+	codes := c.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryableStatusCodes
+	}
+	statusCodes := make(map[int]struct{}, len(codes))
+	for _, code := range codes {
+		statusCodes[code] = struct{}{}
+	}
+
+	return &retryTransport{
+		RoundTripper: rt,
+		maxAttempts:  c.MaxAttempts,
+		minBackoff:   minBackoff,
+		maxBackoff:   maxBackoff,
+		statusCodes:  statusCodes,
+	}, nil
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.RoundTripper.RoundTrip(req)
+		if attempt >= t.maxAttempts || !t.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+		if err != nil {
+			log.Printf("[DEBUG] %s %s failed (%v), retrying in %s", req.Method, req.URL.Path, err, wait)
+		} else {
+			log.Printf("[DEBUG] %s %s returned %d, retrying in %s", req.Method, req.URL.Path, resp.StatusCode, wait)
+			// Drain the body so that the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	// The request can only be sent again if its body can be read again
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		// The request was cancelled by the caller, there is no point in
+		// trying again
+		if req.Context().Err() != nil {
+			return false
+		}
+
+		// Consul uses PUT and POST to create tokens, sessions and to run
+		// transactions, they are only sent again when the first attempt
+		// provably never reached the server
+		return isIdempotent(req.Method) || isDialError(err)
+	}
+
+	if _, ok := t.statusCodes[resp.StatusCode]; !ok {
+		return false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		// The request has been rejected before being handled
+		return true
+	case http.StatusInternalServerError:
+		// Consul returns 500 for most of its errors, only the ones caused by
+		// the state of the cluster are retried
+		return isTransientError(resp)
+	default:
+		// A proxy may have forwarded the request before failing
+		return isIdempotent(req.Method)
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// isDialError reports whether err happened while connecting to the server,
+// before the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isTransientError looks for transientErrors in the body of resp. The part of
+// the body that is read is put back so that the caller can still read the
+// full response.
+func isTransientError(resp *http.Response) bool {
+	if resp.Body == nil {
+		return false
+	}
+
+	prefix, err := io.ReadAll(io.LimitReader(resp.Body, transientErrorPeekSize))
+	resp.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(prefix), resp.Body),
+		Closer: resp.Body,
+	}
+	if err != nil {
+		return false
+	}
+
+	for _, msg := range transientErrors {
+		if strings.Contains(string(prefix), msg) {
+			return true
+		}
+	}
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// backoff returns how long to wait before the next attempt. The Retry-After
+// header sent by Consul when rate limiting requests takes precedence over the
+// exponential backoff, both are capped by maxBackoff.
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	wait := t.minBackoff << (attempt - 1)
+	if wait < t.minBackoff {
+		// The shift overflowed
+		wait = t.maxBackoff
+	}
+
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = d
+		}
+	}
+
+	if wait > t.maxBackoff {
+		wait = t.maxBackoff
+	}
+	return wait
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either a number
+// of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(v); err == nil {
+		d := time.Until(date)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,352 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package consul
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+// flakyHandler fails the first failures requests with the given status code.
+type flakyHandler struct {
+	mu         sync.Mutex
+	failures   int
+	status     int
+	retryAfter string
+	message    string
+	requests   int
+	bodies     []string
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.requests++
+	b, _ := io.ReadAll(req.Body)
+	h.bodies = append(h.bodies, string(b))
+
+	if h.requests <= h.failures {
+		if h.retryAfter != "" {
+			w.Header().Set("Retry-After", h.retryAfter)
+		}
+		w.WriteHeader(h.status)
+		message := h.message
+		if message == "" {
+			message = "No cluster leader"
+		}
+		_, _ = w.Write([]byte(message))
+		return
+	}
+
+	_, _ = w.Write([]byte("ok"))
+}
+
+func testRetryTransport(t *testing.T, c *RetryConfig) *retryTransport {
+	t.Helper()
+
+	rt, err := newRetryTransport(http.DefaultTransport, c)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return rt
+}
+
+func TestRetryTransport(t *testing.T) {
+	testCases := map[string]struct {
+		handler        *flakyHandler
+		config         *RetryConfig
+		expectStatus   int
+		expectRequests int
+	}{
+		"retries-until-success": {
+			handler:        &flakyHandler{failures: 2, status: http.StatusInternalServerError},
+			config:         &RetryConfig{MaxAttempts: 4, MinBackoff: "1ms", MaxBackoff: "10ms"},
+			expectStatus:   http.StatusOK,
+			expectRequests: 3,
+		},
+		"gives-up-after-max-attempts": {
+			handler:        &flakyHandler{failures: 10, status: http.StatusServiceUnavailable},
+			config:         &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "10ms"},
+			expectStatus:   http.StatusServiceUnavailable,
+			expectRequests: 3,
+		},
+		"permanent-internal-error": {
+			handler:        &flakyHandler{failures: 1, status: http.StatusInternalServerError, message: "ACL not found"},
+			config:         &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "10ms"},
+			expectStatus:   http.StatusInternalServerError,
+			expectRequests: 1,
+		},
+		"permanent-rpc-error": {
+			handler:        &flakyHandler{failures: 1, status: http.StatusInternalServerError, message: `rpc error making call: Invalid Intention: a Intention with the same source and destination already exists`},
+			config:         &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "10ms"},
+			expectStatus:   http.StatusInternalServerError,
+			expectRequests: 1,
+		},
+		"rpc-error-getting-client": {
+			handler:        &flakyHandler{failures: 1, status: http.StatusInternalServerError, message: "rpc error getting client: failed to get conn: dial tcp 10.0.0.1:8300: connect: connection refused"},
+			config:         &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "10ms"},
+			expectStatus:   http.StatusOK,
+			expectRequests: 2,
+		},
+		"bad-gateway-not-idempotent": {
+			handler:        &flakyHandler{failures: 1, status: http.StatusBadGateway},
+			config:         &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "10ms"},
+			expectStatus:   http.StatusBadGateway,
+			expectRequests: 1,
+		},
+		"status-not-retryable": {
+			handler:        &flakyHandler{failures: 1, status: http.StatusForbidden},
+			config:         &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "10ms"},
+			expectStatus:   http.StatusForbidden,
+			expectRequests: 1,
+		},
+		"custom-status-codes": {
+			handler: &flakyHandler{failures: 1, status: http.StatusInternalServerError},
+			config: &RetryConfig{
+				MaxAttempts:          3,
+				MinBackoff:           "1ms",
+				MaxBackoff:           "10ms",
+				RetryableStatusCodes: []int{http.StatusTooManyRequests},
+			},
+			expectStatus:   http.StatusInternalServerError,
+			expectRequests: 1,
+		},
+		"single-attempt": {
+			handler:        &flakyHandler{failures: 1, status: http.StatusTooManyRequests},
+			config:         &RetryConfig{MaxAttempts: 1, MinBackoff: "1ms", MaxBackoff: "10ms"},
+			expectStatus:   http.StatusTooManyRequests,
+			expectRequests: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(tc.handler)
+			t.Cleanup(server.Close)
+
+			client := &http.Client{Transport: testRetryTransport(t, tc.config)}
+			resp, err := client.Post(server.URL, "application/json", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.expectStatus {
+				t.Errorf("expected status %d, got %d", tc.expectStatus, resp.StatusCode)
+			}
+			if tc.handler.requests != tc.expectRequests {
+				t.Errorf("expected %d requests, got %d", tc.expectRequests, tc.handler.requests)
+			}
+			for _, body := range tc.handler.bodies {
+				if body != "payload" {
+					t.Errorf("expected the body to be sent on each attempt, got %q", body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransport_badGatewayIdempotent(t *testing.T) {
+	handler := &flakyHandler{failures: 1, status: http.StatusBadGateway}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: testRetryTransport(t, &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "10ms"})}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || handler.requests != 2 {
+		t.Fatalf("expected the GET request to be retried, got status %d after %d requests", resp.StatusCode, handler.requests)
+	}
+}
+
+func TestRetryTransport_permanentErrorBody(t *testing.T) {
+	handler := &flakyHandler{failures: 1, status: http.StatusInternalServerError, message: "Permission denied"}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: testRetryTransport(t, &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "10ms"})}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// The part of the body read to look for transient errors is given back
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "Permission denied" {
+		t.Fatalf("bad body %q", body)
+	}
+}
+
+func TestRetryTransport_retryAfter(t *testing.T) {
+	handler := &flakyHandler{failures: 1, status: http.StatusTooManyRequests, retryAfter: "1"}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: testRetryTransport(t, &RetryConfig{
+		MaxAttempts: 2,
+		MinBackoff:  "1ms",
+		MaxBackoff:  "5s",
+	})}
+
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected Retry-After to be honored, retried after %s", elapsed)
+	}
+}
+
+func TestRetryTransport_connectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	// The connection is refused, the request was never sent and can be
+	// retried whatever its method
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		t.Run(method, func(t *testing.T) {
+			rt := testRetryTransport(t, &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "1ms"})
+			calls := 0
+			rt.RoundTripper = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls++
+				return http.DefaultTransport.RoundTrip(req)
+			})
+
+			req, _ := http.NewRequest(method, url, strings.NewReader("payload"))
+			if _, err := (&http.Client{Transport: rt}).Do(req); err == nil {
+				t.Fatal("expected an error")
+			}
+			if calls != 3 {
+				t.Fatalf("expected 3 attempts, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_connectionErrorAfterSend(t *testing.T) {
+	// The server closes the connection once it has read the request, it may
+	// have handled it
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	testCases := map[string]struct {
+		method         string
+		expectRequests int32
+	}{
+		"put": {http.MethodPut, 1},
+		"get": {http.MethodGet, 3},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			requests.Store(0)
+			client := &http.Client{Transport: testRetryTransport(t, &RetryConfig{MaxAttempts: 3, MinBackoff: "1ms", MaxBackoff: "1ms"})}
+
+			req, _ := http.NewRequest(tc.method, server.URL, strings.NewReader("payload"))
+			if _, err := client.Do(req); err == nil {
+				t.Fatal("expected an error")
+			}
+			if n := requests.Load(); n != tc.expectRequests {
+				t.Fatalf("expected %d requests, got %d", tc.expectRequests, n)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_backoff(t *testing.T) {
+	rt := testRetryTransport(t, &RetryConfig{MaxAttempts: 10, MinBackoff: "1s", MaxBackoff: "5s"})
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if d := rt.backoff(i+1, nil); d != e {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, e, d)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"60"}}}
+	if d := rt.backoff(1, resp); d != 5*time.Second {
+		t.Errorf("expected Retry-After to be capped to 5s, got %s", d)
+	}
+}
+
+func TestNewRetryTransport_invalid(t *testing.T) {
+	testCases := map[string]*RetryConfig{
+		"bad-min-backoff": {MaxAttempts: 1, MinBackoff: "foo", MaxBackoff: "1s"},
+		"min-above-max":   {MaxAttempts: 1, MinBackoff: "10s", MaxBackoff: "1s"},
+		"no-attempt-left": {MaxAttempts: 0, MinBackoff: "1s", MaxBackoff: "1s"},
+		"bad-max-backoff": {MaxAttempts: 1, MinBackoff: "1s", MaxBackoff: "bar"},
+	}
+
+	for name, c := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newRetryTransport(http.DefaultTransport, c); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestResourceProvider_retry(t *testing.T) {
+	handler := &flakyHandler{failures: 2, status: http.StatusInternalServerError}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p := Provider().(*schema.Provider)
+	raw := map[string]interface{}{
+		"address":    strings.TrimPrefix(server.URL, "http://"),
+		"datacenter": "dc1",
+		"retry": []interface{}{
+			map[string]interface{}{
+				"max_attempts": 3,
+				"min_backoff":  "1ms",
+			},
+		},
+	}
+	if err := p.Configure(terraform.NewResourceConfigRaw(raw)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := p.Meta().(*Config).client
+	if _, err := client.Raw().Write("/v1/kv/foo", "bar", nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if handler.requests != 3 {
+		t.Fatalf("expected 3 requests, got %d", handler.requests)
+	}
+}
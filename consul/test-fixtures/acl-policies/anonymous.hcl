@@ -0,0 +1,8 @@
+# Allows the anonymous token to resolve DNS queries
+node_prefix "" {
+  policy = "read"
+}
+
+service_prefix "" {
+  policy = "read"
+}
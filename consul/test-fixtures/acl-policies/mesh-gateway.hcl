@@ -0,0 +1,19 @@
+mesh    = "write"
+peering = "read"
+
+service "mesh-gateway" {
+  policy = "write"
+}
+
+service_prefix "" {
+  policy     = "read"
+  intentions = "read"
+}
+
+node_prefix "" {
+  policy = "read"
+}
+
+agent_prefix "" {
+  policy = "read"
+}
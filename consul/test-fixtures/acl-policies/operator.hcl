@@ -0,0 +1,23 @@
+acl      = "write"
+operator = "write"
+keyring  = "write"
+
+key_prefix "" {
+  policy = "list"
+}
+
+key_prefix "config/" {
+  policy = "write"
+}
+
+query_prefix "" {
+  policy = "read"
+}
+
+event_prefix "" {
+  policy = "write"
+}
+
+event "deploy" {
+  policy = "deny"
+}
@@ -0,0 +1,20 @@
+partition "frontend" {
+  mesh = "read"
+
+  node_prefix "" {
+    policy = "read"
+  }
+
+  namespace "web" {
+    service_prefix "" {
+      policy     = "write"
+      intentions = "write"
+    }
+  }
+}
+
+partition_prefix "" {
+  namespace_prefix "" {
+    acl = "read"
+  }
+}
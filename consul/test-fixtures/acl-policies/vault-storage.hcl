@@ -0,0 +1,15 @@
+key_prefix "vault/" {
+  policy = "write"
+}
+
+service "vault" {
+  policy = "write"
+}
+
+agent_prefix "" {
+  policy = "read"
+}
+
+session_prefix "" {
+  policy = "write"
+}
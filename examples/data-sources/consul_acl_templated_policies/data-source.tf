@@ -0,0 +1,5 @@
+data "consul_acl_templated_policies" "all" {}
+
+output "templated_policies" {
+  value = data.consul_acl_templated_policies.all.templated_policies[*].template_name
+}
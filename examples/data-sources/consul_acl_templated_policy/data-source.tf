@@ -0,0 +1,24 @@
+data "consul_acl_templated_policy" "web" {
+  template_name = "builtin/service"
+
+  template_variables {
+    name = "web"
+  }
+}
+
+# Review the rules before attaching the templated policy to the token
+output "web_rules" {
+  value = data.consul_acl_templated_policy.web.rules
+}
+
+resource "consul_acl_token" "web" {
+  description = "Token of the web service"
+
+  templated_policies {
+    template_name = data.consul_acl_templated_policy.web.template_name
+
+    template_variables {
+      name = "web"
+    }
+  }
+}
@@ -0,0 +1,12 @@
+data "consul_key_tree" "app" {
+  path_prefix = "apps/web/"
+  decode_json = true
+}
+
+locals {
+  config = jsondecode(data.consul_key_tree.app.tree)
+}
+
+output "replicas" {
+  value = local.config.deployment.replicas
+}
@@ -0,0 +1,7 @@
+data "consul_sessions" "node" {
+  node = "worker-1"
+}
+
+output "session_ids" {
+  value = data.consul_sessions.node.sessions[*].id
+}
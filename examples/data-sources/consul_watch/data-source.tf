@@ -0,0 +1,19 @@
+variable "config_index" {
+  description = "The index returned by the previous run."
+  type        = number
+  default     = 0
+}
+
+# Wait for the new configuration to be published before deploying the
+# application.
+data "consul_watch" "config" {
+  type            = "keyprefix"
+  path            = "apps/web/config/"
+  wait_index      = var.config_index
+  timeout         = "10m"
+  fail_on_timeout = true
+}
+
+output "config_index" {
+  value = data.consul_watch.config.index
+}
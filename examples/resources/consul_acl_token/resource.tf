@@ -25,3 +25,17 @@ resource "consul_acl_token" "test_predefined_id" {
   policies    = [consul_acl_policy.agent.name]
   local       = true
 }
+
+# Use a secret ID generated by Vault and export it
+
+variable "agent_secret_id" {
+  type      = string
+  sensitive = true
+}
+
+resource "consul_acl_token" "agent" {
+  description              = "agent token"
+  policies                 = [consul_acl_policy.agent.name]
+  secret_id                = var.agent_secret_id
+  store_secret_id_in_state = true
+}
@@ -0,0 +1,27 @@
+resource "consul_acl_policy" "web" {
+  name  = "web"
+  rules = <<-RULE
+    service "web" {
+      policy = "write"
+    }
+    RULE
+}
+
+# The source token is never used directly, it only holds the permissions
+# copied to the rotated tokens
+resource "consul_acl_token" "web" {
+  description = "web (source of the rotated tokens)"
+  policies    = [consul_acl_policy.web.name]
+}
+
+resource "consul_acl_token_rotation" "web" {
+  source_accessor_id = consul_acl_token.web.id
+  description        = "web"
+  rotation_period    = "2160h"
+  grace_period       = "72h"
+  pgp_key            = "keybase:my_username"
+}
+
+output "web_encrypted_secret_id" {
+  value = consul_acl_token_rotation.web.encrypted_secret_id
+}
@@ -0,0 +1,15 @@
+resource "consul_lock" "terraform" {
+  path  = "terraform/locks/production"
+  value = "pipeline-42"
+}
+
+# The resources that must not be changed concurrently depend on the lock so
+# that they are only applied once it has been acquired.
+resource "consul_keys" "app" {
+  key {
+    path  = "app/config/version"
+    value = "1.2.3"
+  }
+
+  depends_on = [consul_lock.terraform]
+}
@@ -0,0 +1,14 @@
+# The session is not renewed by Terraform, the application using it must call
+# the /v1/session/renew endpoint before the TTL expires.
+resource "consul_session" "leader" {
+  name       = "leader-election"
+  ttl        = "60s"
+  behavior   = "delete"
+  lock_delay = "30s"
+}
+
+resource "consul_prepared_query" "leader" {
+  name    = "leader"
+  service = "app"
+  session = consul_session.leader.id
+}
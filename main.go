@@ -4,11 +4,31 @@
 package main
 
 import (
+	"time"
+
 	"github.com/hashicorp/terraform-plugin-sdk/plugin"
 	"github.com/hashicorp/terraform-provider-consul/consul"
 )
 
+// shutdownTimeout bounds the cleanup done once the plugin stops serving,
+// Terraform kills the plugin about 2s after asking it to shut down.
+const shutdownTimeout = 1500 * time.Millisecond
+
 func main() {
 	plugin.Serve(&plugin.ServeOpts{
 		ProviderFunc: consul.Provider})
+
+	// Serve returns once Terraform has asked the plugin to shut down. The
+	// locks are released first since doing so needs the login tokens.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consul.ReleaseLocks()
+		consul.LogoutLoginTokens()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+	}
 }